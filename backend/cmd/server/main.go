@@ -2,11 +2,13 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"kiro-finance-backend/internal/api"
 	"kiro-finance-backend/internal/config"
 	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/services"
 )
 
 func main() {
@@ -18,6 +20,13 @@ func main() {
 	log.Printf("Config loaded: port=%s, db=%s, gin_mode=%s",
 		config.Cfg.Port, config.Cfg.DBPath, config.Cfg.GinMode)
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Set Gin mode
 	gin.SetMode(config.Cfg.GinMode)
 
@@ -27,6 +36,19 @@ func main() {
 	}
 	defer db.Close()
 
+	if path := config.Cfg.ExchangeRatesPath; path != "" {
+		count, err := services.LoadExchangeRatesFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load exchange rates from %s: %v", path, err)
+		}
+		log.Printf("Loaded %d exchange rates from %s", count, path)
+	}
+
+	// Convert amounts to the configured base currency
+	if err := services.RefreshBaseAmounts(); err != nil {
+		log.Fatalf("Failed to convert amounts to %s: %v", services.BaseCurrency(), err)
+	}
+
 	// Setup router
 	router := api.SetupRouter()
 
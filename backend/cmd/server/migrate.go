@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"kiro-finance-backend/internal/db"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  status          list migrations and whether they are applied
+  up [version]    apply pending migrations, up to version if given
+  down [steps]    revert the last applied migrations (default 1)`
+
+// runMigrate handles the "migrate" subcommand
+func runMigrate(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+
+	number := 0
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number %q\n%s", args[1], migrateUsage)
+		}
+		number = n
+	}
+
+	if err := db.Open(); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		return printMigrationStatus()
+	case "up":
+		applied, err := db.MigrateUp(number)
+		fmt.Printf("Applied %d migrations\n", applied)
+		return err
+	case "down":
+		if number == 0 {
+			number = 1
+		}
+		reverted, err := db.MigrateDown(number)
+		fmt.Printf("Reverted %d migrations\n", reverted)
+		return err
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func printMigrationStatus() error {
+	states, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range states {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/services"
+)
+
+// Category handlers
+
+var colorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func GetCategoryList(c *gin.Context) {
+	categories, err := services.GetAllCategories()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+func GetCategory(c *gin.Context) {
+	category, err := services.GetCategoryByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if category == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+func CreateCategory(c *gin.Context) {
+	var req models.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateCategory(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if existing, err := services.FindCategoryByName(req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category with this name already exists"})
+		return
+	}
+
+	category, err := services.CreateCategory(req)
+	if err == services.ErrCategoryParent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory also renames the category on every transaction and
+// recurring pattern when the name changes
+func UpdateCategory(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateCategory(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if existing, err := services.FindCategoryByName(req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if existing != nil && existing.ID != id {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category with this name already exists, merge the categories instead"})
+		return
+	}
+
+	category, err := services.UpdateCategory(id, req, requestActor(c))
+	if err == services.ErrCategoryParent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if category == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// MergeCategory moves everything using a category to the one named by "into"
+// (an ID) and deletes it
+func MergeCategory(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.CategoryMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Into == "" || req.Into == id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "into must be the ID of another category"})
+		return
+	}
+
+	category, err := services.MergeCategory(id, req.Into, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if category == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+func DeleteCategory(c *gin.Context) {
+	err := services.DeleteCategory(c.Param("id"))
+	if err == services.ErrCategoryInUse {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
+
+// validateCategory trims the request and returns an error message, or an
+// empty string when the category is usable
+func validateCategory(req *models.CategoryRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if req.ParentID != nil && *req.ParentID == "" {
+		req.ParentID = nil
+	}
+	if req.Color != nil && *req.Color == "" {
+		req.Color = nil
+	}
+	if req.Color != nil && !colorRegex.MatchString(*req.Color) {
+		return "color must be a hex color such as #ff8800"
+	}
+	if req.Icon != nil && *req.Icon == "" {
+		req.Icon = nil
+	}
+	return ""
+}
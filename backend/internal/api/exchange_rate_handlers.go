@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/parser"
+	"kiro-finance-backend/internal/services"
+)
+
+// Exchange rate handlers
+
+func GetExchangeRates(c *gin.Context) {
+	rates, err := services.GetExchangeRates(c.Query("currency"), c.Query("date_from"), c.Query("date_to"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if rates == nil {
+		rates = []models.ExchangeRate{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"baseCurrency": services.BaseCurrency(),
+		"rates":        rates,
+	})
+}
+
+// UploadExchangeRates loads an NBP-style rates CSV and reconverts all
+// transactions to the base currency
+func UploadExchangeRates(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer f.Close()
+
+	rates, err := parser.ParseExchangeRates(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse file: " + err.Error()})
+		return
+	}
+
+	if err := services.SaveExchangeRates(rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rateCount": len(rates)})
+}
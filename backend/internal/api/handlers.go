@@ -1,9 +1,15 @@
 package api
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"kiro-finance-backend/internal/models"
@@ -13,6 +19,8 @@ import (
 
 // Files handlers
 
+const defaultPreviewLimit = 20
+
 func GetFiles(c *gin.Context) {
 	files, err := services.GetAllFiles()
 	if err != nil {
@@ -50,45 +58,72 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	if !strings.HasSuffix(strings.ToLower(file.Filename), ".csv") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only CSV files are allowed"})
+	if !parser.IsSupported(file.Filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": unsupportedFileMessage()})
 		return
 	}
 
-	// Create file record
-	fileRecord, err := services.CreateFile(file.Filename)
+	opts, err := parseOptions(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Open and parse CSV
-	f, err := file.Open()
+	duplicates, err := parseDuplicatePolicy(c)
 	if err != nil {
-		services.DeleteFile(fileRecord.ID)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer f.Close()
 
-	transactions, err := parser.ParseCSV(f, fileRecord.ID)
+	data, err := readUpload(file)
 	if err != nil {
-		services.DeleteFile(fileRecord.ID)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse CSV"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
 		return
 	}
+	upload := services.NewUpload(file.Filename, data)
 
-	// Save transactions
-	if err := services.SaveTransactions(transactions); err != nil {
-		services.DeleteFile(fileRecord.ID)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save transactions"})
+	results, err := parser.ParseAll(bytes.NewReader(data), file.Filename, "", opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse file: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"file":             fileRecord,
-		"transactionCount": len(transactions),
-	})
+	// The same bytes are only imported again when forced; a workbook may be
+	// uploaded again for sheets not imported yet
+	if formValue(c, "force") != "true" {
+		existing, err := alreadyUploaded(upload, results)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(existing) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "This file was already uploaded; pass force=true to import it again",
+				"files": existing,
+			})
+			return
+		}
+	}
+
+	// One file record per workbook sheet, removed together on failure
+	var sheets []gin.H
+	for _, result := range results {
+		fileRecord, err := services.ImportParsed(upload, result, duplicates)
+		if err != nil {
+			for _, done := range sheets {
+				services.DeleteFile(done["file"].(*models.File).ID)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		sheets = append(sheets, importResponse(fileRecord, result))
+	}
+
+	response := importResponse(sheets[0]["file"].(*models.File), results[0])
+	if results[0].Layout.Format == parser.FormatXLSX {
+		response["sheets"] = sheets
+	}
+	c.JSON(http.StatusCreated, response)
 }
 
 func ReimportFile(c *gin.Context) {
@@ -111,13 +146,158 @@ func ReimportFile(c *gin.Context) {
 		return
 	}
 
-	// Delete existing transactions
-	if err := services.DeleteTransactionsByFileID(id); err != nil {
+	if !parser.IsSupported(file.Filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": unsupportedFileMessage()})
+		return
+	}
+
+	data, err := readUpload(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+
+	reimport(c, existingFile, services.NewUpload(file.Filename, data))
+}
+
+// ReparseFile runs the current parser, or the file's import profile, on the
+// stored original upload again
+func ReparseFile(c *gin.Context) {
+	id := c.Param("id")
+
+	existingFile, err := services.GetFileByID(id)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Parse new CSV
+	if existingFile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	data, err := services.ReadUploadContent(existingFile)
+	if err == services.ErrUploadNotStored {
+		c.JSON(http.StatusConflict, gin.H{"error": "Original upload is not stored, upload the file again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	reimport(c, existingFile, services.NewUpload(existingFile.Name, data))
+}
+
+// reimport replaces a file's transactions with the parsed upload. The sheet
+// and import profile default to the ones the file was imported with.
+func reimport(c *gin.Context, existingFile *models.File, upload services.Upload) {
+	if existingFile.ID == models.ManualFileID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Manual entries cannot be reimported"})
+		return
+	}
+
+	opts, err := parseOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	duplicates, err := parseDuplicatePolicy(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(opts.Sheets) == 0 && existingFile.SheetName != nil {
+		opts.Sheets = []string{*existingFile.SheetName}
+	}
+	if opts.Profile == nil && existingFile.ProfileID != nil {
+		opts.Profile, err = services.GetImportProfileByID(*existingFile.ProfileID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	result, err := parser.Parse(bytes.NewReader(upload.Data), upload.Name, existingFile.ID, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse file: " + err.Error()})
+		return
+	}
+
+	// Stored rows are only replaced once the whole reimport succeeds
+	dryRun := formValue(c, "dry_run") == "true"
+	diff, err := services.ReimportFile(existingFile, upload, result, duplicates, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reimport file: " + err.Error()})
+		return
+	}
+
+	response := importResponse(existingFile, result)
+	response["diff"] = diff
+	c.JSON(http.StatusOK, response)
+}
+
+// DownloadFile sends the original upload of a file
+func DownloadFile(c *gin.Context) {
+	file, err := services.GetFileByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if file == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	data, err := services.ReadUploadContent(file)
+	if err == services.ErrUploadNotStored {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Original upload is not stored"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// PreviewFile runs the import pipeline on an upload without storing anything
+func PreviewFile(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		return
+	}
+
+	if !parser.IsSupported(file.Filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": unsupportedFileMessage()})
+		return
+	}
+
+	opts, err := parseOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	duplicates, err := parseDuplicatePolicy(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultPreviewLimit
+	if limitStr := formValue(c, "limit"); limitStr != "" {
+		if val, err := strconv.Atoi(limitStr); err == nil && val >= 0 {
+			limit = val
+		}
+	}
+
 	f, err := file.Open()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
@@ -125,24 +305,79 @@ func ReimportFile(c *gin.Context) {
 	}
 	defer f.Close()
 
-	transactions, err := parser.ParseCSV(f, id)
+	result, err := parser.Parse(f, file.Filename, "", opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse CSV"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse file: " + err.Error()})
 		return
 	}
 
-	// Save new transactions
-	if err := services.SaveTransactions(transactions); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save transactions"})
+	if err := services.NormalizeSources(result.Transactions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.NormalizeCategories(result.Transactions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ApplyRules(result.Transactions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.AutoCategorize(result.Transactions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ApplyDuplicatePolicy(result, duplicates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if err := services.ConvertToBase(result.Transactions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	unconverted := 0
+	for _, t := range result.Transactions {
+		if t.AmountBase == nil {
+			unconverted++
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"file":             existingFile,
-		"transactionCount": len(transactions),
+	sample := result.Transactions
+	if len(sample) > limit {
+		sample = sample[:limit]
+	}
+	if sample == nil {
+		sample = []models.Transaction{}
+	}
+
+	c.JSON(http.StatusOK, models.ImportPreview{
+		FileName:         file.Filename,
+		Layout:           result.Layout,
+		TransactionCount: len(result.Transactions),
+		Transactions:     sample,
+		Report:           result.Report,
+		Categories:       services.CategoryTotalsFromTransactions(result.Transactions),
+		UnconvertedCount: unconverted,
 	})
 }
 
+func GetImportReport(c *gin.Context) {
+	id := c.Param("id")
+
+	report, err := services.GetImportReport(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import report not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 func DeleteFile(c *gin.Context) {
 	id := c.Param("id")
 
@@ -193,59 +428,386 @@ func GetTransaction(c *gin.Context) {
 	c.JSON(http.StatusOK, tx)
 }
 
+func CreateTransaction(c *gin.Context) {
+	var req models.TransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateTransaction(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	created, err := services.CreateTransactions([]models.TransactionRequest{req})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created[0])
+}
+
+// CreateTransactionBatch stores a JSON array of transactions; nothing is
+// stored if any of them is invalid
+func CreateTransactionBatch(c *gin.Context) {
+	var reqs []models.TransactionRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(reqs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No transactions provided"})
+		return
+	}
+	for i := range reqs {
+		if msg := validateTransaction(&reqs[i]); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("transaction %d: %s", i, msg)})
+			return
+		}
+	}
+
+	created, err := services.CreateTransactions(reqs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// validateTransaction trims and completes a manual transaction and returns
+// an error message, or an empty string when it can be stored
+func validateTransaction(req *models.TransactionRequest) string {
+	req.Category = strings.TrimSpace(req.Category)
+	req.Source = strings.TrimSpace(req.Source)
+	req.Description = strings.TrimSpace(req.Description)
+	req.Bank = strings.TrimSpace(req.Bank)
+	if req.Source == "" && req.Description == "" {
+		return "source or description is required"
+	}
+	tags, msg := normalizeTags(req.Tags)
+	if msg != "" {
+		return msg
+	}
+	req.Tags = tags
+	if req.Amount <= 0 {
+		return "amount must be a positive number"
+	}
+
+	switch req.Direction {
+	case "":
+		req.Direction = models.DirectionDebit
+	case models.DirectionDebit, models.DirectionCredit:
+	default:
+		return "direction must be debit or credit"
+	}
+
+	if req.Currency == "" {
+		req.Currency = services.BaseCurrency()
+	} else if len(req.Currency) != 3 || strings.ToUpper(req.Currency) != req.Currency {
+		return "currency must be a 3-letter ISO code"
+	}
+
+	if req.TransactionDate == nil || *req.TransactionDate == "" {
+		today := time.Now().Format("2006-01-02")
+		req.TransactionDate = &today
+	} else if _, err := time.Parse("2006-01-02", *req.TransactionDate); err != nil {
+		return "transactionDate must be YYYY-MM-DD"
+	}
+	return ""
+}
+
 func UpdateTransaction(c *gin.Context) {
 	id := c.Param("id")
 
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
+	var patch models.TransactionPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Map JSON fields to DB columns
-	dbUpdates := make(map[string]interface{})
-	fieldMap := map[string]string{
-		"category":        "category",
-		"source":          "source",
-		"description":     "description",
-		"amount":          "amount",
-		"isPaid":          "is_paid",
-		"transactionDate": "transaction_date",
-	}
-
-	for jsonField, dbField := range fieldMap {
-		if val, ok := updates[jsonField]; ok {
-			if jsonField == "isPaid" {
-				if boolVal, ok := val.(bool); ok {
-					if boolVal {
-						dbUpdates[dbField] = 1
-					} else {
-						dbUpdates[dbField] = 0
-					}
-				}
-			} else {
-				dbUpdates[dbField] = val
-			}
+	if msg := validateTransactionPatch(&patch); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tx, err := services.UpdateTransaction(id, patch, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tx == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tx)
+}
+
+// validateTransactionPatch trims the edited fields and returns an error
+// message, or an empty string when the patch can be applied
+func validateTransactionPatch(patch *models.TransactionPatch) string {
+	for _, field := range []*string{patch.Category, patch.Source, patch.Description, patch.Bank, patch.TransactionDate} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
 		}
 	}
 
-	if err := services.UpdateTransaction(id, dbUpdates); err != nil {
+	if patch.Amount != nil && *patch.Amount < 0 {
+		return "amount must be a non-negative number"
+	}
+	if patch.Direction != nil && *patch.Direction != models.DirectionDebit && *patch.Direction != models.DirectionCredit {
+		return "direction must be debit or credit"
+	}
+	if currency := patch.Currency; currency != nil && (len(*currency) != 3 || strings.ToUpper(*currency) != *currency) {
+		return "currency must be a 3-letter ISO code"
+	}
+	if date := patch.TransactionDate; date != nil && *date != "" {
+		if _, err := time.Parse("2006-01-02", *date); err != nil {
+			return "transactionDate must be YYYY-MM-DD, or empty to clear it"
+		}
+	}
+	return ""
+}
+
+// GetDuplicateTransactions lists groups of transactions that look like the
+// same bank transaction imported from overlapping files
+func GetDuplicateTransactions(c *gin.Context) {
+	groups, err := services.GetDuplicateGroups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if groups == nil {
+		groups = []models.DuplicateGroup{}
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+func ResolveDuplicateTransactions(c *gin.Context) {
+	var resolution models.DuplicateResolution
+	if err := c.ShouldBindJSON(&resolution); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(resolution.Delete) == 0 && len(resolution.Keep) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "delete or keep must list transaction IDs"})
+		return
+	}
+
+	if err := services.ResolveDuplicates(resolution, requestActor(c)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Transaction updated"})
+	c.JSON(http.StatusOK, gin.H{"message": "Duplicates resolved"})
 }
 
 func DeleteTransaction(c *gin.Context) {
 	id := c.Param("id")
 
-	if err := services.DeleteTransaction(id); err != nil {
+	found, err := services.DeleteTransaction(id, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction moved to trash"})
+}
+
+func GetTransactionHistory(c *gin.Context) {
+	history, err := services.GetTransactionHistory(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetTransactionSuggestions lists the categories a transaction most likely
+// belongs to, learned from the other transactions; limit defaults to 3
+func GetTransactionSuggestions(c *gin.Context) {
+	limit := 3
+	if l := c.Query("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil && val > 0 {
+			limit = val
+		}
+	}
+
+	suggestions, err := services.SuggestCategories(c.Param("id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if suggestions == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// UndoTransactionChange reverts the latest change of a transaction, which
+// restores it if it was deleted
+func UndoTransactionChange(c *gin.Context) {
+	tx, err := services.UndoTransactionChange(c.Param("id"), requestActor(c))
+	if err == services.ErrNothingToUndo {
+		c.JSON(http.StatusConflict, gin.H{"error": "Nothing to undo"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tx == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tx)
+}
+
+func GetTrash(c *gin.Context) {
+	transactions, err := services.GetTrash()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
+// PurgeTrash permanently deletes the transactions listed in "ids", or the
+// whole trash
+func PurgeTrash(c *gin.Context) {
+	var ids []string
+	if value := c.Query("ids"); value != "" {
+		ids = strings.Split(value, ",")
+	}
+
+	purged, err := services.PurgeTrash(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// requestActor names who made a change: the X-Actor header when the client
+// sends one, otherwise its address
+func requestActor(c *gin.Context) string {
+	if actor := strings.TrimSpace(c.GetHeader("X-Actor")); actor != "" {
+		return actor
+	}
+	return c.ClientIP()
+}
+
+// bulkPreviewSample is how many matching transactions a bulk preview lists
+const bulkPreviewSample = 20
+
+// BulkUpdateTransactions applies the fields in the body to every transaction
+// matching the query filter; with preview=true it only reports the matches
+func BulkUpdateTransactions(c *gin.Context) {
+	filter, ok := bulkFilter(c)
+	if !ok {
+		return
+	}
+
+	var patch models.TransactionPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if patch == (models.TransactionPatch{}) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+	if msg := validateTransactionPatch(&patch); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if c.Query("preview") == "true" {
+		previewBulk(c, filter)
+		return
+	}
+
+	updated, err := services.BulkUpdateTransactions(filter, patch, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// BulkDeleteTransactions deletes every transaction matching the query
+// filter; with preview=true it only reports the matches
+func BulkDeleteTransactions(c *gin.Context) {
+	filter, ok := bulkFilter(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("preview") == "true" {
+		previewBulk(c, filter)
+		return
+	}
+
+	deleted, err := services.BulkDeleteTransactions(filter, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// bulkFilter reads the filter of a bulk operation. An empty filter would
+// match every transaction, so it must be confirmed with all=true.
+func bulkFilter(c *gin.Context) (models.TransactionFilter, bool) {
+	filter := parseFilter(c)
+	empty := len(filter.FileIDs) == 0 && len(filter.FileNames) == 0 &&
+		len(filter.Categories) == 0 && len(filter.Sources) == 0 &&
+		len(filter.ExcludeCategories) == 0 && len(filter.ExcludeSources) == 0 &&
+		len(filter.Tags) == 0 && len(filter.ExcludeTags) == 0 &&
+		filter.IsPaid == nil && filter.DateFrom == nil && filter.DateTo == nil && filter.Direction == nil
+	if empty && c.Query("all") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A filter is required, or all=true to match every transaction"})
+		return filter, false
+	}
+	return filter, true
+}
+
+func previewBulk(c *gin.Context, filter models.TransactionFilter) {
+	result, err := services.GetTransactions(filter, 1, bulkPreviewSample)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Transaction deleted"})
+	preview := models.BulkPreview{Count: result.Pagination.TotalItems, Sample: result.Data}
+	if preview.Sample == nil {
+		preview.Sample = []models.Transaction{}
+	}
+	c.JSON(http.StatusOK, preview)
 }
 
 // Stats handlers
@@ -265,7 +827,17 @@ func GetSummary(c *gin.Context) {
 func GetCategories(c *gin.Context) {
 	filter := parseFilter(c)
 
-	categories, err := services.GetCategoryTotals(filter)
+	// level rolls categories up to their ancestors, 1 being top-level
+	level := 0
+	if value := c.Query("level"); value != "" {
+		var err error
+		if level, err = strconv.Atoi(value); err != nil || level < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "level must be a non-negative number"})
+			return
+		}
+	}
+
+	categories, err := services.GetCategoryTotals(filter, level)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -294,6 +866,24 @@ func GetSources(c *gin.Context) {
 	c.JSON(http.StatusOK, sources)
 }
 
+// GetTags sums transactions per tag; one with several tags counts towards
+// each
+func GetTags(c *gin.Context) {
+	filter := parseFilter(c)
+
+	tags, err := services.GetTagTotals(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tags == nil {
+		tags = []models.TagTotal{}
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
 func GetTopCategory(c *gin.Context) {
 	filter := parseFilter(c)
 
@@ -313,6 +903,108 @@ func GetTopCategory(c *gin.Context) {
 
 // Helper functions
 
+// formValue reads a multipart form field, falling back to the query string
+func formValue(c *gin.Context, key string) string {
+	if value := c.PostForm(key); value != "" {
+		return value
+	}
+	return c.Query(key)
+}
+
+func unsupportedFileMessage() string {
+	return "Unsupported file type, allowed: " + strings.Join(parser.SupportedExtensions(), ", ")
+}
+
+func readUpload(file *multipart.FileHeader) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// alreadyUploaded returns the stored files holding the same upload and
+// sheet as any of the parsed results
+func alreadyUploaded(upload services.Upload, results []*parser.Result) ([]models.File, error) {
+	files, err := services.FindFilesByHash(upload.ContentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []models.File
+	for _, f := range files {
+		sheet := ""
+		if f.SheetName != nil {
+			sheet = *f.SheetName
+		}
+		for _, result := range results {
+			if result.Layout.Sheet == sheet {
+				existing = append(existing, f)
+				break
+			}
+		}
+	}
+	return existing, nil
+}
+
+func importResponse(file *models.File, result *parser.Result) gin.H {
+	return gin.H{
+		"file":             file,
+		"transactionCount": len(result.Transactions),
+		"layout":           result.Layout,
+		"report":           result.Report,
+	}
+}
+
+// parseOptions builds parser options from the "profile" (ID or name),
+// "encoding" and "sheets" upload parameters
+func parseOptions(c *gin.Context) (parser.Options, error) {
+	var opts parser.Options
+
+	if ref := strings.TrimSpace(formValue(c, "profile")); ref != "" {
+		profile, err := services.FindImportProfile(ref)
+		if err != nil {
+			return opts, err
+		}
+		if profile == nil {
+			return opts, fmt.Errorf("import profile %q not found", ref)
+		}
+		opts.Profile = profile
+	}
+
+	if name := formValue(c, "encoding"); name != "" {
+		encoding, err := parser.NormalizeEncoding(name)
+		if err != nil {
+			return opts, err
+		}
+		opts.Encoding = encoding
+	}
+
+	if sheets := formValue(c, "sheets"); sheets != "" {
+		for _, name := range strings.Split(sheets, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts.Sheets = append(opts.Sheets, name)
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// parseDuplicatePolicy reads how an import handles rows already imported
+// from other files; flagging is the default
+func parseDuplicatePolicy(c *gin.Context) (string, error) {
+	switch policy := formValue(c, "duplicates"); policy {
+	case "":
+		return models.DuplicatesFlag, nil
+	case models.DuplicatesSkip, models.DuplicatesKeep, models.DuplicatesFlag:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("duplicates must be %s, %s or %s", models.DuplicatesSkip, models.DuplicatesKeep, models.DuplicatesFlag)
+	}
+}
+
 func parseFilter(c *gin.Context) models.TransactionFilter {
 	filter := models.TransactionFilter{}
 
@@ -324,6 +1016,14 @@ func parseFilter(c *gin.Context) models.TransactionFilter {
 		filter.FileNames = strings.Split(fileNames, ",")
 	}
 
+	if categories := c.Query("categories"); categories != "" {
+		filter.Categories = strings.Split(categories, ",")
+	}
+
+	if sources := c.Query("sources"); sources != "" {
+		filter.Sources = strings.Split(sources, ",")
+	}
+
 	if excludeCategories := c.Query("exclude_categories"); excludeCategories != "" {
 		filter.ExcludeCategories = strings.Split(excludeCategories, ",")
 	}
@@ -332,6 +1032,14 @@ func parseFilter(c *gin.Context) models.TransactionFilter {
 		filter.ExcludeSources = strings.Split(excludeSources, ",")
 	}
 
+	if tags := c.Query("tags"); tags != "" {
+		filter.Tags = strings.Split(tags, ",")
+	}
+
+	if excludeTags := c.Query("exclude_tags"); excludeTags != "" {
+		filter.ExcludeTags = strings.Split(excludeTags, ",")
+	}
+
 	if isPaid := c.Query("is_paid"); isPaid != "" {
 		val := isPaid == "true"
 		filter.IsPaid = &val
@@ -345,6 +1053,18 @@ func parseFilter(c *gin.Context) models.TransactionFilter {
 		filter.DateTo = &dateTo
 	}
 
+	filter.IncludeDuplicates = c.Query("include_duplicates") == "true"
+
+	// income and expense are accepted as aliases
+	switch c.Query("direction") {
+	case models.DirectionDebit, "expense":
+		direction := models.DirectionDebit
+		filter.Direction = &direction
+	case models.DirectionCredit, "income":
+		direction := models.DirectionCredit
+		filter.Direction = &direction
+	}
+
 	return filter
 }
 
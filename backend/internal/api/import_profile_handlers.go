@@ -0,0 +1,140 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/services"
+)
+
+// Import profile handlers
+
+func GetImportProfiles(c *gin.Context) {
+	profiles, err := services.GetAllImportProfiles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if profiles == nil {
+		profiles = []models.ImportProfile{}
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+func GetImportProfile(c *gin.Context) {
+	profile, err := services.GetImportProfileByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if profile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import profile not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func CreateImportProfile(c *gin.Context) {
+	var req models.ImportProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateImportProfile(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if existing, err := services.FindImportProfile(req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Import profile with this name already exists"})
+		return
+	}
+
+	profile, err := services.CreateImportProfile(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, profile)
+}
+
+func UpdateImportProfile(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.ImportProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateImportProfile(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if existing, err := services.FindImportProfile(req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if existing != nil && existing.ID != id {
+		c.JSON(http.StatusConflict, gin.H{"error": "Import profile with this name already exists"})
+		return
+	}
+
+	profile, err := services.UpdateImportProfile(id, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if profile == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import profile not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func DeleteImportProfile(c *gin.Context) {
+	if err := services.DeleteImportProfile(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Import profile deleted"})
+}
+
+// validateImportProfile trims the request and returns an error message, or
+// an empty string when the profile is usable
+func validateImportProfile(req *models.ImportProfileRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if req.HeaderRow < 0 {
+		return "headerRow must be 0 (detect) or a row number"
+	}
+	if len([]rune(req.Delimiter)) > 1 {
+		return "delimiter must be a single character, or empty to detect"
+	}
+	if strings.TrimSpace(req.Columns.Amount) == "" &&
+		strings.TrimSpace(req.Columns.Debit) == "" && strings.TrimSpace(req.Columns.Credit) == "" {
+		return "columns.amount, or columns.debit and columns.credit, is required"
+	}
+	switch req.AmountSign {
+	case "", models.AmountSignExpensePositive, models.AmountSignExpenseNegative:
+	default:
+		return "amountSign must be " + models.AmountSignExpensePositive + " or " + models.AmountSignExpenseNegative
+	}
+	return ""
+}
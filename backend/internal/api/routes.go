@@ -15,8 +15,8 @@ func SetupRouter() *gin.Engine {
 	origins := strings.Split(config.Cfg.CORSOrigins, ",")
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     origins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "X-Actor"},
 		AllowCredentials: true,
 	}))
 
@@ -26,21 +26,83 @@ func SetupRouter() *gin.Engine {
 		api.GET("/files", GetFiles)
 		api.GET("/files/:id", GetFile)
 		api.POST("/files", UploadFile)
+		api.POST("/files/preview", PreviewFile)
 		api.PUT("/files/:id", ReimportFile)
 		api.DELETE("/files/:id", DeleteFile)
+		api.GET("/files/:id/import-report", GetImportReport)
+		api.GET("/files/:id/raw", DownloadFile)
+		api.POST("/files/:id/reparse", ReparseFile)
+
+		// Import profiles
+		api.GET("/import-profiles", GetImportProfiles)
+		api.GET("/import-profiles/:id", GetImportProfile)
+		api.POST("/import-profiles", CreateImportProfile)
+		api.PUT("/import-profiles/:id", UpdateImportProfile)
+		api.DELETE("/import-profiles/:id", DeleteImportProfile)
 
 		// Transactions
 		api.GET("/transactions", GetTransactions)
+		api.POST("/transactions", CreateTransaction)
+		api.POST("/transactions/batch", CreateTransactionBatch)
+		api.PATCH("/transactions", BulkUpdateTransactions)
+		api.DELETE("/transactions", BulkDeleteTransactions)
+		api.GET("/transactions/duplicates", GetDuplicateTransactions)
+		api.POST("/transactions/duplicates/resolve", ResolveDuplicateTransactions)
+		api.GET("/transactions/trash", GetTrash)
+		api.DELETE("/transactions/trash", PurgeTrash)
 		api.GET("/transactions/:id", GetTransaction)
+		api.GET("/transactions/:id/history", GetTransactionHistory)
+		api.GET("/transactions/:id/suggestions", GetTransactionSuggestions)
+		api.POST("/transactions/:id/tags", AttachTransactionTags)
+		api.DELETE("/transactions/:id/tags", DetachTransactionTags)
+		api.POST("/transactions/:id/undo", UndoTransactionChange)
 		api.PUT("/transactions/:id", UpdateTransaction)
 		api.DELETE("/transactions/:id", DeleteTransaction)
 
+		// Categories
+		api.GET("/categories", GetCategoryList)
+		api.GET("/categories/:id", GetCategory)
+		api.POST("/categories", CreateCategory)
+		api.PUT("/categories/:id", UpdateCategory)
+		api.POST("/categories/:id/merge", MergeCategory)
+		api.DELETE("/categories/:id", DeleteCategory)
+
+		// Sources
+		api.GET("/sources", GetSourceList)
+		api.GET("/sources/suggestions", GetSourceSuggestions)
+		api.GET("/sources/:id", GetSource)
+		api.POST("/sources", CreateSource)
+		api.POST("/sources/apply", ApplySourceAliases)
+		api.PUT("/sources/:id", UpdateSource)
+		api.DELETE("/sources/:id", DeleteSource)
+
+		// Tags
+		api.GET("/tags", GetTagList)
+		api.GET("/tags/:id", GetTag)
+		api.POST("/tags", CreateTag)
+		api.PUT("/tags/:id", UpdateTag)
+		api.DELETE("/tags/:id", DeleteTag)
+
+		// Rules
+		api.GET("/rules", GetRules)
+		api.GET("/rules/:id", GetRule)
+		api.POST("/rules", CreateRule)
+		api.POST("/rules/apply", ApplyRules)
+		api.PUT("/rules/order", ReorderRules)
+		api.PUT("/rules/:id", UpdateRule)
+		api.DELETE("/rules/:id", DeleteRule)
+
 		// Stats
 		api.GET("/stats/summary", GetSummary)
 		api.GET("/stats/categories", GetCategories)
 		api.GET("/stats/sources", GetSources)
+		api.GET("/stats/tags", GetTags)
 		api.GET("/stats/top-category", GetTopCategory)
 
+		// Exchange rates
+		api.GET("/exchange-rates", GetExchangeRates)
+		api.POST("/exchange-rates", UploadExchangeRates)
+
 		// Recurring
 		api.GET("/recurring", GetRecurringPatterns)
 		api.GET("/recurring/:id", GetRecurringPattern)
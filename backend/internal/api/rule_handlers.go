@@ -0,0 +1,218 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/services"
+)
+
+// Rule handlers
+
+func GetRules(c *gin.Context) {
+	rules, err := services.GetAllRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func GetRule(c *gin.Context) {
+	rule, err := services.GetRuleByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func CreateRule(c *gin.Context) {
+	var req models.RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateRule(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	rule, err := services.CreateRule(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func UpdateRule(c *gin.Context) {
+	var req models.RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateRule(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	rule, err := services.UpdateRule(c.Param("id"), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func DeleteRule(c *gin.Context) {
+	if err := services.DeleteRule(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rule deleted"})
+}
+
+// ReorderRules sets the order rules run in from a list of every rule ID
+func ReorderRules(c *gin.Context) {
+	var req models.RuleOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules, err := services.ReorderRules(req.IDs)
+	if err == services.ErrRuleOrder {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// ApplyRules runs the enabled rules over the stored transactions matching
+// the transaction filters; dry_run=true only reports the changes
+func ApplyRules(c *gin.Context) {
+	result, err := services.ApplyRulesToTransactions(parseFilter(c), c.Query("dry_run") == "true", requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// validateRule trims the request and returns an error message, or an empty
+// string when the rule is usable
+func validateRule(req *models.RuleRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+
+	if len(req.Conditions) == 0 {
+		return "At least one condition is required"
+	}
+	for i := range req.Conditions {
+		if msg := validateRuleCondition(&req.Conditions[i]); msg != "" {
+			return fmt.Sprintf("Condition %d: %s", i+1, msg)
+		}
+	}
+
+	a := &req.Actions
+	for _, value := range []*string{a.Category, a.Source} {
+		if value != nil {
+			*value = strings.TrimSpace(*value)
+		}
+	}
+	tags, msg := normalizeTags(a.Tags)
+	if msg != "" {
+		return msg
+	}
+	a.Tags = nil
+	if len(tags) > 0 {
+		a.Tags = tags
+	}
+	if a.Category == nil && a.Source == nil && a.IsPaid == nil && len(a.Tags) == 0 {
+		return "At least one action is required"
+	}
+	if a.Source != nil && *a.Source == "" {
+		return "Source action must not be empty"
+	}
+	return ""
+}
+
+func validateRuleCondition(cond *models.RuleCondition) string {
+	switch cond.Field {
+	case models.RuleFieldSource, models.RuleFieldDescription, models.RuleFieldBank:
+		cond.Min, cond.Max, cond.From, cond.To = nil, nil, "", ""
+		if cond.Operator == "" {
+			cond.Operator = models.RuleOpContains
+		}
+		if cond.Value == "" {
+			return "value is required"
+		}
+		switch cond.Operator {
+		case models.RuleOpEquals, models.RuleOpContains, models.RuleOpPrefix:
+		case models.RuleOpRegex:
+			if _, err := regexp.Compile(cond.Value); err != nil {
+				return "invalid regex: " + err.Error()
+			}
+		default:
+			return "operator must be equals, contains, prefix or regex"
+		}
+	case models.RuleFieldAmount:
+		cond.Operator, cond.Value, cond.From, cond.To = "", "", "", ""
+		if cond.Min == nil && cond.Max == nil {
+			return "min or max is required"
+		}
+		if (cond.Min != nil && *cond.Min < 0) || (cond.Max != nil && *cond.Max < 0) {
+			return "amounts are positive, min and max must not be negative"
+		}
+		if cond.Min != nil && cond.Max != nil && *cond.Min > *cond.Max {
+			return "min must not be greater than max"
+		}
+	case models.RuleFieldDate:
+		cond.Operator, cond.Value, cond.Min, cond.Max = "", "", nil, nil
+		if cond.From == "" && cond.To == "" {
+			return "from or to is required"
+		}
+		for _, date := range []string{cond.From, cond.To} {
+			if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+				return "from and to must be YYYY-MM-DD"
+			}
+		}
+		if cond.From != "" && cond.To != "" && cond.From > cond.To {
+			return "from must not be after to"
+		}
+	default:
+		return "field must be source, description, bank, amount or date"
+	}
+	return ""
+}
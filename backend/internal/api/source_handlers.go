@@ -0,0 +1,179 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/services"
+)
+
+// Source handlers
+
+func GetSourceList(c *gin.Context) {
+	sources, err := services.GetAllSources()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sources)
+}
+
+func GetSource(c *gin.Context) {
+	source, err := services.GetSourceByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if source == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Source not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+func CreateSource(c *gin.Context) {
+	var req models.SourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateSource(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if existing, err := services.FindSourceByName(req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Source with this name already exists"})
+		return
+	}
+
+	source, err := services.CreateSource(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, source)
+}
+
+// UpdateSource also renames the source on every transaction and recurring
+// pattern when the name changes
+func UpdateSource(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.SourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateSource(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if existing, err := services.FindSourceByName(req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if existing != nil && existing.ID != id {
+		c.JSON(http.StatusConflict, gin.H{"error": "Source with this name already exists, add the spelling as its alias instead"})
+		return
+	}
+
+	source, err := services.UpdateSource(id, req, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if source == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Source not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+func DeleteSource(c *gin.Context) {
+	if err := services.DeleteSource(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Source deleted"})
+}
+
+// ApplySourceAliases renames stored transactions to the canonical sources
+// their aliases match; dry_run=true only reports the renames
+func ApplySourceAliases(c *gin.Context) {
+	result, err := services.ApplySourceAliases(c.Query("dry_run") == "true", requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetSourceSuggestions lists groups of similar sources worth merging
+func GetSourceSuggestions(c *gin.Context) {
+	minSimilarity := 0.85
+	if ms := c.Query("min_similarity"); ms != "" {
+		if val, err := strconv.ParseFloat(ms, 64); err == nil {
+			minSimilarity = val
+		}
+	}
+
+	suggestions, err := services.SuggestSourceMerges(minSimilarity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// validateSource trims the request and returns an error message, or an
+// empty string when the source is usable
+func validateSource(req *models.SourceRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if req.Aliases == nil {
+		req.Aliases = []models.SourceAlias{}
+	}
+	for i := range req.Aliases {
+		a := &req.Aliases[i]
+		if a.Match == "" {
+			a.Match = models.AliasExact
+		}
+		if a.Match != models.AliasRegex {
+			a.Pattern = strings.TrimSpace(a.Pattern)
+		}
+		if a.Pattern == "" {
+			return "Alias pattern is required"
+		}
+		switch a.Match {
+		case models.AliasExact, models.AliasPrefix:
+		case models.AliasRegex:
+			if _, err := regexp.Compile(a.Pattern); err != nil {
+				return "Invalid alias regex " + a.Pattern + ": " + err.Error()
+			}
+		default:
+			return "Alias match must be exact, prefix or regex"
+		}
+	}
+	return ""
+}
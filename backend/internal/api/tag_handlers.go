@@ -0,0 +1,207 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/services"
+)
+
+// Tag handlers
+
+func GetTagList(c *gin.Context) {
+	tags, err := services.GetAllTags()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+func GetTag(c *gin.Context) {
+	tag, err := services.GetTagByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tag == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+func CreateTag(c *gin.Context) {
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateTag(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if existing, err := services.FindTagByName(req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag with this name already exists"})
+		return
+	}
+
+	tag, err := services.CreateTag(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+func UpdateTag(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateTag(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if existing, err := services.FindTagByName(req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if existing != nil && existing.ID != id {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag with this name already exists"})
+		return
+	}
+
+	tag, err := services.UpdateTag(id, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tag == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag also removes the tag from every transaction
+func DeleteTag(c *gin.Context) {
+	if err := services.DeleteTag(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted"})
+}
+
+// AttachTransactionTags adds tags, by name, to a transaction; unknown names
+// become new tags
+func AttachTransactionTags(c *gin.Context) {
+	var req models.TransactionTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags, msg := normalizeTags(req.Tags)
+	if msg == "" && len(tags) == 0 {
+		msg = "At least one tag is required"
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tx, err := services.AttachTransactionTags(c.Param("id"), tags, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tx == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tx)
+}
+
+// DetachTransactionTags removes the tags named by the comma-separated tags
+// query parameter from a transaction
+func DetachTransactionTags(c *gin.Context) {
+	tags, _ := normalizeTags(strings.Split(c.Query("tags"), ","))
+	if len(tags) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tags is required"})
+		return
+	}
+
+	tx, err := services.DetachTransactionTags(c.Param("id"), tags, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tx == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tx)
+}
+
+// validateTag trims the request and returns an error message, or an empty
+// string when the tag is usable
+func validateTag(req *models.TagRequest) string {
+	tags, msg := normalizeTags([]string{req.Name})
+	if msg != "" {
+		return msg
+	}
+	if len(tags) == 0 {
+		return "Name is required"
+	}
+	req.Name = tags[0]
+
+	if req.Color != nil && *req.Color == "" {
+		req.Color = nil
+	}
+	if req.Color != nil && !colorRegex.MatchString(*req.Color) {
+		return "color must be a hex color such as #ff8800"
+	}
+	return ""
+}
+
+// normalizeTags trims tag names and drops empty and repeated ones. Names
+// cannot contain commas, which separate them in filters.
+func normalizeTags(names []string) ([]string, string) {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if strings.Contains(name, ",") {
+			return nil, "Tag names cannot contain commas"
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags, ""
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 
 	"github.com/caarlos0/env/v11"
 )
@@ -12,6 +13,16 @@ type Config struct {
 	DBPath      string `json:"db_path" env:"DB_PATH" envDefault:"./data/finance.db"`
 	GinMode     string `json:"gin_mode" env:"GIN_MODE" envDefault:"debug"`
 	CORSOrigins string `json:"cors_origins" env:"CORS_ORIGINS" envDefault:"http://localhost:5173,http://localhost:5300"`
+	// Currency that stats are reported in, and the NBP-style rates CSV
+	// loaded into exchange_rates at startup (optional)
+	BaseCurrency      string `json:"base_currency" env:"BASE_CURRENCY" envDefault:"PLN"`
+	ExchangeRatesPath string `json:"exchange_rates_path" env:"EXCHANGE_RATES_PATH"`
+	// Original uploads, stored by content hash; defaults to uploads/ next
+	// to the database
+	UploadsDir string `json:"uploads_dir" env:"UPLOADS_DIR"`
+	// Minimum confidence at which an import replaces an empty or often
+	// corrected category with the suggested one; 0 turns this off
+	CategoryAutoApply float64 `json:"category_auto_apply" env:"CATEGORY_AUTO_APPLY" envDefault:"0"`
 }
 
 var Cfg *Config
@@ -31,5 +42,9 @@ func Load() error {
 		return err
 	}
 
+	if Cfg.UploadsDir == "" {
+		Cfg.UploadsDir = filepath.Join(filepath.Dir(Cfg.DBPath), "uploads")
+	}
+
 	return nil
 }
@@ -0,0 +1,282 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// baselineSchema creates the schema as it was before migrations were
+// versioned. Every step is idempotent so that databases created by earlier
+// builds, at any stage, are brought up to the same baseline.
+func baselineSchema(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS files (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			uploaded_at INTEGER NOT NULL,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_files_name ON files(name)`,
+		`CREATE TABLE IF NOT EXISTS transactions (
+			id TEXT PRIMARY KEY,
+			file_id TEXT NOT NULL,
+			category TEXT NOT NULL,
+			source TEXT NOT NULL,
+			description TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			amount_original TEXT NOT NULL,
+			is_paid INTEGER NOT NULL DEFAULT 0,
+			bank TEXT,
+			transaction_date TEXT,
+			created_at INTEGER NOT NULL,
+			FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_file_id ON transactions(file_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_category ON transactions(category)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_source ON transactions(source)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_is_paid ON transactions(is_paid)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_date ON transactions(transaction_date)`,
+		// Recurring patterns
+		`CREATE TABLE IF NOT EXISTS recurring_patterns (
+			id TEXT PRIMARY KEY,
+			source TEXT NOT NULL,
+			category TEXT NOT NULL,
+			description_pattern TEXT,
+			avg_amount INTEGER NOT NULL,
+			min_amount INTEGER,
+			max_amount INTEGER,
+			amount_variance REAL,
+			frequency TEXT,
+			avg_interval_days INTEGER,
+			interval_variance REAL,
+			last_occurrence TEXT,
+			next_expected TEXT,
+			occurrence_count INTEGER NOT NULL,
+			confidence REAL NOT NULL,
+			detection_mode TEXT NOT NULL,
+			is_confirmed INTEGER,
+			user_label TEXT,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_recurring_source ON recurring_patterns(source)`,
+		`CREATE INDEX IF NOT EXISTS idx_recurring_category ON recurring_patterns(category)`,
+		`CREATE INDEX IF NOT EXISTS idx_recurring_confidence ON recurring_patterns(confidence DESC)`,
+		// Junction table
+		`CREATE TABLE IF NOT EXISTS recurring_transactions (
+			pattern_id TEXT NOT NULL,
+			transaction_id TEXT NOT NULL,
+			PRIMARY KEY (pattern_id, transaction_id),
+			FOREIGN KEY (pattern_id) REFERENCES recurring_patterns(id) ON DELETE CASCADE,
+			FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_recurring_tx_pattern ON recurring_transactions(pattern_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_recurring_tx_transaction ON recurring_transactions(transaction_id)`,
+		// Import profiles
+		`CREATE TABLE IF NOT EXISTS import_profiles (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			header_row INTEGER NOT NULL,
+			delimiter TEXT NOT NULL DEFAULT '',
+			columns TEXT NOT NULL,
+			paid_marker TEXT NOT NULL,
+			date_formats TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		// Import reports (one per file, replaced on reimport)
+		`CREATE TABLE IF NOT EXISTS import_reports (
+			file_id TEXT PRIMARY KEY,
+			total_rows INTEGER NOT NULL,
+			imported_rows INTEGER NOT NULL,
+			skipped_rows INTEGER NOT NULL,
+			issues TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
+		)`,
+		// Daily exchange rates: value of one unit of currency in PLN (NBP table A)
+		`CREATE TABLE IF NOT EXISTS exchange_rates (
+			currency TEXT NOT NULL,
+			rate_date TEXT NOT NULL,
+			rate REAL NOT NULL,
+			PRIMARY KEY (currency, rate_date)
+		)`,
+	}
+
+	for _, s := range statements {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+
+	// Columns added after the tables were first created
+	columns := []struct{ table, column, definition string }{
+		{"transactions", "external_id", "TEXT"},
+		{"files", "opening_balance", "INTEGER"},
+		{"files", "closing_balance", "INTEGER"},
+		{"files", "sheet_name", "TEXT"},
+		{"transactions", "direction", "TEXT NOT NULL DEFAULT 'debit'"},
+		{"import_profiles", "amount_sign", "TEXT NOT NULL DEFAULT ''"},
+		{"transactions", "currency", "TEXT NOT NULL DEFAULT ''"},
+		{"transactions", "amount_base", "INTEGER"},
+	}
+
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// Money columns hold minor units; databases created before that stored
+	// REAL major units
+	moneyColumns := []struct {
+		table   string
+		columns []string
+	}{
+		{"transactions", []string{"amount", "amount_base"}},
+		{"files", []string{"opening_balance", "closing_balance"}},
+		{"recurring_patterns", []string{"avg_amount", "min_amount", "max_amount"}},
+	}
+
+	for _, m := range moneyColumns {
+		if err := convertToMinorUnits(tx, m.table, m.columns); err != nil {
+			return fmt.Errorf("converting %s amounts to minor units: %w", m.table, err)
+		}
+	}
+
+	// Amounts are unsigned since transactions carry a direction; rows saved
+	// before that kept money in as negative amounts
+	if _, err := tx.Exec(`UPDATE transactions SET direction = 'credit', amount = -amount WHERE amount < 0`); err != nil {
+		return err
+	}
+
+	// Indexes on added columns
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_transactions_external_id ON transactions(file_id, external_id)`,
+	}
+
+	for _, s := range indexes {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	types, err := columnTypes(tx, table)
+	if err != nil {
+		return err
+	}
+	if _, ok := types[column]; ok {
+		return nil
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// columnTypes returns the declared type of each column of table
+func columnTypes(tx *sql.Tx, table string) (map[string]string, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		types[name] = strings.ToUpper(colType)
+	}
+	return types, rows.Err()
+}
+
+// convertToMinorUnits rebuilds table with the given REAL columns declared
+// INTEGER and their values multiplied by 100. SQLite cannot change a column
+// type in place, and a REAL column would turn stored integers back into
+// floats. The table keeps its name, constraints and indexes; the migration
+// runner disables foreign keys so the table can be dropped.
+func convertToMinorUnits(tx *sql.Tx, table string, columns []string) error {
+	types, err := columnTypes(tx, table)
+	if err != nil {
+		return err
+	}
+	var convert []string
+	for _, c := range columns {
+		if types[c] == "REAL" {
+			convert = append(convert, c)
+		}
+	}
+	if len(convert) == 0 {
+		return nil
+	}
+
+	var createSQL string
+	if err := tx.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&createSQL); err != nil {
+		return err
+	}
+
+	var indexSQL []string
+	rows, err := tx.Query("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", table)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			rows.Close()
+			return err
+		}
+		indexSQL = append(indexSQL, s)
+	}
+	rows.Close()
+
+	newTable := table + "_minor_units"
+	createSQL = regexp.MustCompile(`^CREATE TABLE (IF NOT EXISTS )?"?`+table+`"?`).
+		ReplaceAllString(createSQL, "CREATE TABLE "+newTable)
+	for _, c := range convert {
+		createSQL = regexp.MustCompile(`\b`+c+`\s+REAL\b`).ReplaceAllString(createSQL, c+" INTEGER")
+	}
+
+	names := make([]string, 0, len(types))
+	values := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+		value := name
+		for _, c := range convert {
+			if c == name {
+				value = fmt.Sprintf("CAST(ROUND(%s * 100) AS INTEGER)", name)
+			}
+		}
+		values = append(values, value)
+	}
+
+	statements := []string{
+		createSQL,
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+			newTable, strings.Join(names, ", "), strings.Join(values, ", "), table),
+		"DROP TABLE " + table,
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", newTable, table),
+	}
+	statements = append(statements, indexSQL...)
+
+	for _, s := range statements {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Converted %s.%s to minor units", table, strings.Join(convert, ", "))
+	return nil
+}
@@ -0,0 +1,508 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// migration is one numbered schema change. Up and Down run inside a
+// transaction with foreign keys disabled, so tables can be rebuilt.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+}
+
+// migrations are applied in order; append new ones with the next version and
+// never edit one that has been released
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "baseline schema",
+		Up:      baselineSchema,
+		Down: execAll(
+			`DROP TABLE IF EXISTS exchange_rates`,
+			`DROP TABLE IF EXISTS import_reports`,
+			`DROP TABLE IF EXISTS import_profiles`,
+			`DROP TABLE IF EXISTS recurring_transactions`,
+			`DROP TABLE IF EXISTS recurring_patterns`,
+			`DROP TABLE IF EXISTS transactions`,
+			`DROP TABLE IF EXISTS files`,
+		),
+	},
+	{
+		Version: 2,
+		Name:    "transaction fingerprints",
+		Up: func(tx *sql.Tx) error {
+			err := execAll(
+				`ALTER TABLE transactions ADD COLUMN fingerprint TEXT NOT NULL DEFAULT ''`,
+				`ALTER TABLE transactions ADD COLUMN duplicate_of TEXT REFERENCES transactions(id) ON DELETE SET NULL`,
+				`ALTER TABLE transactions ADD COLUMN not_duplicate INTEGER NOT NULL DEFAULT 0`,
+				`ALTER TABLE import_reports ADD COLUMN duplicate_rows INTEGER NOT NULL DEFAULT 0`,
+				`CREATE INDEX idx_transactions_fingerprint ON transactions(fingerprint)`,
+			)(tx)
+			if err != nil {
+				return err
+			}
+			return backfillFingerprints(tx)
+		},
+		Down: execAll(
+			`DROP INDEX idx_transactions_fingerprint`,
+			`ALTER TABLE import_reports DROP COLUMN duplicate_rows`,
+			`ALTER TABLE transactions DROP COLUMN not_duplicate`,
+			`ALTER TABLE transactions DROP COLUMN duplicate_of`,
+			`ALTER TABLE transactions DROP COLUMN fingerprint`,
+		),
+	},
+	{
+		Version: 3,
+		Name:    "file content hashes",
+		Up: execAll(
+			`ALTER TABLE files ADD COLUMN size INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE files ADD COLUMN content_hash TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX idx_files_content_hash ON files(content_hash)`,
+		),
+		Down: execAll(
+			`DROP INDEX idx_files_content_hash`,
+			`ALTER TABLE files DROP COLUMN content_hash`,
+			`ALTER TABLE files DROP COLUMN size`,
+		),
+	},
+	{
+		Version: 4,
+		Name:    "track edited transaction fields",
+		Up: execAll(
+			`ALTER TABLE transactions ADD COLUMN edited_fields TEXT NOT NULL DEFAULT ''`,
+		),
+		Down: execAll(
+			`ALTER TABLE transactions DROP COLUMN edited_fields`,
+		),
+	},
+	{
+		Version: 5,
+		Name:    "file import profile",
+		Up: execAll(
+			`ALTER TABLE files ADD COLUMN profile_id TEXT REFERENCES import_profiles(id) ON DELETE SET NULL`,
+		),
+		Down: execAll(
+			`ALTER TABLE files DROP COLUMN profile_id`,
+		),
+	},
+	{
+		Version: 6,
+		Name:    "transaction history and trash",
+		Up: execAll(
+			`ALTER TABLE transactions ADD COLUMN deleted_at INTEGER`,
+			`CREATE INDEX idx_transactions_deleted_at ON transactions(deleted_at)`,
+			`CREATE TABLE transaction_history (
+				id TEXT PRIMARY KEY,
+				transaction_id TEXT NOT NULL,
+				action TEXT NOT NULL,
+				fields TEXT NOT NULL DEFAULT '',
+				before_state TEXT,
+				after_state TEXT,
+				actor TEXT NOT NULL DEFAULT '',
+				created_at INTEGER NOT NULL,
+				undone_at INTEGER,
+				FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX idx_transaction_history_transaction_id ON transaction_history(transaction_id)`,
+		),
+		Down: execAll(
+			`DROP TABLE transaction_history`,
+			`DELETE FROM transactions WHERE deleted_at IS NOT NULL`,
+			`DROP INDEX idx_transactions_deleted_at`,
+			`ALTER TABLE transactions DROP COLUMN deleted_at`,
+		),
+	},
+	{
+		Version: 7,
+		Name:    "categories",
+		Up: func(tx *sql.Tx) error {
+			err := execAll(
+				`CREATE TABLE categories (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL UNIQUE,
+					parent_id TEXT REFERENCES categories(id) ON DELETE SET NULL,
+					color TEXT,
+					icon TEXT,
+					aliases TEXT NOT NULL DEFAULT '[]',
+					created_at INTEGER NOT NULL,
+					updated_at INTEGER NOT NULL
+				)`,
+				`CREATE INDEX idx_categories_parent_id ON categories(parent_id)`,
+			)(tx)
+			if err != nil {
+				return err
+			}
+			return seedCategories(tx)
+		},
+		Down: execAll(
+			`DROP TABLE categories`,
+		),
+	},
+	{
+		Version: 8,
+		Name:    "sources",
+		Up: execAll(
+			`CREATE TABLE sources (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL UNIQUE,
+				aliases TEXT NOT NULL DEFAULT '[]',
+				created_at INTEGER NOT NULL,
+				updated_at INTEGER NOT NULL
+			)`,
+		),
+		Down: execAll(
+			`DROP TABLE sources`,
+		),
+	},
+	{
+		Version: 9,
+		Name:    "rules",
+		Up: execAll(
+			`CREATE TABLE rules (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				position INTEGER NOT NULL,
+				enabled INTEGER NOT NULL DEFAULT 1,
+				stop INTEGER NOT NULL DEFAULT 0,
+				conditions TEXT NOT NULL DEFAULT '[]',
+				actions TEXT NOT NULL DEFAULT '{}',
+				created_at INTEGER NOT NULL,
+				updated_at INTEGER NOT NULL
+			)`,
+			`CREATE INDEX idx_rules_position ON rules(position)`,
+		),
+		Down: execAll(
+			`DROP TABLE rules`,
+		),
+	},
+	{
+		Version: 10,
+		Name:    "tags",
+		Up: execAll(
+			`CREATE TABLE tags (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL UNIQUE,
+				color TEXT,
+				created_at INTEGER NOT NULL,
+				updated_at INTEGER NOT NULL
+			)`,
+			`CREATE TABLE transaction_tags (
+				transaction_id TEXT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+				tag_id TEXT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+				created_at INTEGER NOT NULL,
+				PRIMARY KEY (transaction_id, tag_id)
+			)`,
+			`CREATE INDEX idx_transaction_tags_tag_id ON transaction_tags(tag_id)`,
+		),
+		Down: execAll(
+			`DROP TABLE transaction_tags`,
+			`DROP TABLE tags`,
+		),
+	},
+}
+
+// execAll is a migration step running plain SQL statements
+func execAll(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, s := range statements {
+			if _, err := tx.Exec(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// MigrationState is a known migration and when it was applied
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil when pending
+}
+
+// MigrationStatus lists every known migration in order
+func MigrationStatus() ([]MigrationState, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		states[i] = MigrationState{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			t := time.Unix(at, 0)
+			states[i].AppliedAt = &t
+		}
+	}
+	return states, nil
+}
+
+// MigrateUp applies pending migrations up to and including target, or all of
+// them when target is 0, and returns how many were applied
+func MigrateUp(target int) (int, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkKnownVersions(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := runMigration(m, true); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown reverts the given number of most recently applied migrations
+// and returns how many were reverted
+func MigrateDown(steps int) (int, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkKnownVersions(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if err := runMigration(m, false); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func appliedMigrations() (map[int]int64, error) {
+	if _, err := DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]int64)
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// checkKnownVersions refuses to touch a database migrated by a newer build
+func checkKnownVersions(applied map[int]int64) error {
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("database has migration %d applied, which this build does not know; upgrade the server", version)
+		}
+	}
+	return nil
+}
+
+func runMigration(m migration, up bool) error {
+	// The pragma is per connection and ignored inside a transaction
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	step, direction := m.Up, "up"
+	if !up {
+		step, direction = m.Down, "down"
+	}
+	if err := step(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) %s: %w", m.Version, m.Name, direction, err)
+	}
+
+	if err := checkForeignKeys(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) %s: %w", m.Version, m.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			m.Version, m.Name, time.Now().Unix())
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkForeignKeys fails when a migration left rows pointing nowhere, which
+// SQLite does not catch while foreign keys are off
+func checkForeignKeys(tx *sql.Tx) error {
+	rows, err := tx.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var fkID int
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return err
+		}
+		return fmt.Errorf("foreign key violation: %s row %d references missing %s", table, rowID.Int64, parent)
+	}
+	return rows.Err()
+}
+
+func backfillFingerprints(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT id, transaction_date, amount, direction, source, description, COALESCE(bank, '')
+		FROM transactions
+	`)
+	if err != nil {
+		return err
+	}
+
+	fingerprints := make(map[string]string)
+	for rows.Next() {
+		var id, direction, source, description, bank string
+		var date sql.NullString
+		var amount int64
+		if err := rows.Scan(&id, &date, &amount, &direction, &source, &description, &bank); err != nil {
+			rows.Close()
+			return err
+		}
+		fingerprints[id] = fingerprintV2(date.String, amount, direction, source, description, bank)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("UPDATE transactions SET fingerprint = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, fingerprint := range fingerprints {
+		if _, err := stmt.Exec(fingerprint, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fingerprintV2 is Transaction.ComputeFingerprint as it was when migration 2
+// was written, kept here so later changes to it do not alter the backfill
+func fingerprintV2(date string, amount int64, direction, source, description, bank string) string {
+	if direction == "credit" {
+		amount = -amount
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+	fields := []string{
+		date,
+		fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100),
+		normalize(source),
+		normalize(description),
+		normalize(bank),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// seedCategories creates a category for every name already in use
+func seedCategories(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT category FROM transactions WHERE category != ''
+		UNION
+		SELECT category FROM recurring_patterns WHERE category != ''
+	`)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, name := range names {
+		if _, err := tx.Exec("INSERT INTO categories (id, name, created_at, updated_at) VALUES (?, ?, ?, ?)",
+			uuid.New().String(), name, now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
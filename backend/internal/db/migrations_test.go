@@ -0,0 +1,126 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kiro-finance-backend/internal/config"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	config.Cfg = &config.Config{DBPath: filepath.Join(t.TempDir(), "finance.db")}
+	if err := Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(Close)
+}
+
+// schema describes every table's columns and every index, leaving out the
+// migration bookkeeping
+func schema(t *testing.T) string {
+	t.Helper()
+	rows, err := DB.Query(`
+		SELECT type, name, tbl_name FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'
+		ORDER BY type, name
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objects [][3]string
+	for rows.Next() {
+		var o [3]string
+		if err := rows.Scan(&o[0], &o[1], &o[2]); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+
+	var b strings.Builder
+	for _, o := range objects {
+		fmt.Fprintf(&b, "%s %s on %s\n", o[0], o[1], o[2])
+		if o[0] != "table" {
+			continue
+		}
+		columns, err := DB.Query("SELECT name, type, \"notnull\", COALESCE(dflt_value, ''), pk FROM pragma_table_info(?)", o[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for columns.Next() {
+			var name, typ, dflt string
+			var notNull, pk int
+			if err := columns.Scan(&name, &typ, &notNull, &dflt, &pk); err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprintf(&b, "  %s %s notnull=%d default=%s pk=%d\n", name, typ, notNull, dflt, pk)
+		}
+		columns.Close()
+	}
+	return b.String()
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	openTestDB(t)
+
+	// schemas[i] is the schema with the first i migrations applied
+	schemas := []string{schema(t)}
+	for _, m := range migrations {
+		if _, err := MigrateUp(m.Version); err != nil {
+			t.Fatal(err)
+		}
+		if m.Version == 1 {
+			if _, err := DB.Exec(`INSERT INTO files (id, name, uploaded_at, created_at, updated_at) VALUES ('f1', 'a.csv', 1, 1, 1)`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := DB.Exec(`
+				INSERT INTO transactions (id, file_id, category, source, description, amount, amount_original, is_paid, transaction_date, created_at)
+				VALUES ('t1', 'f1', 'Food', 'Shop', 'Bread', 1250, '12,50', 1, '2024-01-05', 1)
+			`); err != nil {
+				t.Fatal(err)
+			}
+		}
+		schemas = append(schemas, schema(t))
+	}
+
+	for i := len(migrations) - 1; i >= 1; i-- {
+		m := migrations[i]
+		t.Run(fmt.Sprintf("%d %s", m.Version, m.Name), func(t *testing.T) {
+			if n, err := MigrateDown(1); err != nil || n != 1 {
+				t.Fatalf("MigrateDown(1) = %d, %v", n, err)
+			}
+			if got := schema(t); got != schemas[i] {
+				t.Errorf("schema after down differs from before up\ngot:\n%s\nwant:\n%s", got, schemas[i])
+			}
+
+			var amount int64
+			var source string
+			if err := DB.QueryRow("SELECT amount, source FROM transactions WHERE id = 't1'").Scan(&amount, &source); err != nil {
+				t.Fatalf("transaction lost: %v", err)
+			}
+			if amount != 1250 || source != "Shop" {
+				t.Errorf("transaction = %d %s, want 1250 Shop", amount, source)
+			}
+
+			if _, err := MigrateUp(m.Version); err != nil {
+				t.Fatal(err)
+			}
+			if got := schema(t); got != schemas[i+1] {
+				t.Errorf("schema after up again differs\ngot:\n%s\nwant:\n%s", got, schemas[i+1])
+			}
+			if _, err := MigrateDown(1); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	if _, err := MigrateDown(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := schema(t); got != schemas[0] {
+		t.Errorf("schema after reverting everything:\n%s", got)
+	}
+}
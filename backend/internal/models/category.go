@@ -0,0 +1,34 @@
+package models
+
+// Category describes a category name used by transactions, which refer to it
+// by name
+type Category struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	ParentID         *string  `json:"parentId"`
+	Color            *string  `json:"color"` // #rrggbb
+	Icon             *string  `json:"icon"`
+	Aliases          []string `json:"aliases"` // former names, mapped to this one on import
+	TransactionCount int      `json:"transactionCount"`
+	CreatedAt        int64    `json:"createdAt"`
+	UpdatedAt        int64    `json:"updatedAt"`
+}
+
+type CategoryRequest struct {
+	Name     string  `json:"name"`
+	ParentID *string `json:"parentId"`
+	Color    *string `json:"color"`
+	Icon     *string `json:"icon"`
+}
+
+// CategoryMergeRequest names the category another one is merged into
+type CategoryMergeRequest struct {
+	Into string `json:"into"`
+}
+
+// CategorySuggestion is a category a classifier trained on stored
+// transactions proposes for one, with its probability
+type CategorySuggestion struct {
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"` // 0-1
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// What to do with imported rows that match a transaction in another file
+const (
+	DuplicatesSkip = "skip" // do not import them
+	DuplicatesKeep = "keep" // import them as usual
+	DuplicatesFlag = "flag" // import them marked as duplicates, left out of stats
+)
+
+// ComputeFingerprint identifies the same bank transaction across overlapping
+// exports by date, signed amount, source, description and bank. Text is
+// compared case-insensitively with whitespace collapsed.
+func (t *Transaction) ComputeFingerprint() string {
+	date := ""
+	if t.TransactionDate != nil {
+		date = *t.TransactionDate
+	}
+	amount := t.Amount
+	if t.Direction == DirectionCredit {
+		amount = -amount
+	}
+
+	fields := []string{
+		date,
+		amount.String(),
+		normalizeFingerprintText(t.Source),
+		normalizeFingerprintText(t.Description),
+		normalizeFingerprintText(t.Bank),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:16])
+}
+
+func normalizeFingerprintText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// DuplicateGroup is a set of transactions in different files sharing a
+// fingerprint
+type DuplicateGroup struct {
+	Fingerprint  string        `json:"fingerprint"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// DuplicateResolution settles a duplicate group: transactions in Delete are
+// removed, those in Keep are marked as not duplicates
+type DuplicateResolution struct {
+	Delete []string `json:"delete"`
+	Keep   []string `json:"keep"`
+}
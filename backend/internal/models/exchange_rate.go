@@ -0,0 +1,9 @@
+package models
+
+// ExchangeRate is the value of one unit of Currency in Polish zloty on a
+// day, as published in NBP table A
+type ExchangeRate struct {
+	Currency string  `json:"currency"`
+	Date     string  `json:"date"` // yyyy-MM-dd
+	Rate     float64 `json:"rate"`
+}
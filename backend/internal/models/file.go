@@ -1,9 +1,24 @@
 package models
 
+// ManualFileID is the file that transactions entered through the API belong
+// to; it is created with the first of them
+const ManualFileID = "manual"
+
 type File struct {
-	ID         string `json:"id"`
-	Name       string `json:"name"`
-	UploadedAt int64  `json:"uploadedAt"`
-	CreatedAt  int64  `json:"createdAt"`
-	UpdatedAt  int64  `json:"updatedAt"`
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	UploadedAt int64   `json:"uploadedAt"`
+	SheetName  *string `json:"sheetName"` // workbook sheet the file row was imported from
+	Size       int64   `json:"size"`      // bytes uploaded
+	// Hex SHA-256 of the uploaded bytes, empty for files uploaded before
+	// hashes were recorded
+	ContentHash string `json:"contentHash"`
+	RowCount    int    `json:"rowCount"` // transactions in the file
+	// Import profile the file was parsed with, reused on reparse
+	ProfileID *string `json:"profileId"`
+	// Statement balances (credit positive), set for MT940 and CAMT.053 imports
+	OpeningBalance *Money `json:"openingBalance"`
+	ClosingBalance *Money `json:"closingBalance"`
+	CreatedAt      int64  `json:"createdAt"`
+	UpdatedAt      int64  `json:"updatedAt"`
 }
@@ -0,0 +1,35 @@
+package models
+
+const (
+	HistoryUpdate = "update"
+	HistoryDelete = "delete"
+	HistoryUndo   = "undo" // reverts an update or restores from the trash
+
+	// A flagged copy took the place of its original, which went to the
+	// trash; restoring the original flags it again
+	HistoryPromote = "promote"
+)
+
+// SystemActorPrefix marks changes the app made on its own, such as renaming
+// a category on its transactions, rather than edits by hand
+const SystemActorPrefix = "system:"
+
+// SystemActor is the actor of an automated run started by actor, e.g.
+// "system:categories:10.0.0.1"
+func SystemActor(run, actor string) string {
+	return SystemActorPrefix + run + ":" + actor
+}
+
+// TransactionHistory is one recorded change of a transaction with its state
+// before and after
+type TransactionHistory struct {
+	ID            string       `json:"id"`
+	TransactionID string       `json:"transactionId"`
+	Action        string       `json:"action"`
+	Fields        []string     `json:"fields"` // columns an update changed
+	Before        *Transaction `json:"before"`
+	After         *Transaction `json:"after"`
+	Actor         string       `json:"actor"`
+	CreatedAt     int64        `json:"createdAt"`
+	UndoneAt      *int64       `json:"undoneAt"`
+}
@@ -0,0 +1,62 @@
+package models
+
+// Sign conventions of a single amount column
+const (
+	AmountSignExpensePositive = "expense_positive" // spending sheet: minus marks money in
+	AmountSignExpenseNegative = "expense_negative" // bank export: minus marks money out
+)
+
+// ImportProfile describes how a CSV export is laid out: where the header
+// sits, which header names map to which transaction fields, how the paid
+// column marks a paid row, which date formats to try and how the sign of
+// an amount maps to a direction.
+type ImportProfile struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	HeaderRow   int           `json:"headerRow"` // 1-based row number of the header, 0 to detect
+	Delimiter   string        `json:"delimiter"` // empty to detect
+	Columns     ColumnMapping `json:"columns"`
+	PaidMarker  string        `json:"paidMarker"`
+	DateFormats []string      `json:"dateFormats"` // Go time layouts
+	AmountSign  string        `json:"amountSign"`  // empty means AmountSignExpensePositive
+	CreatedAt   int64         `json:"createdAt"`
+	UpdatedAt   int64         `json:"updatedAt"`
+}
+
+// ColumnMapping maps transaction fields to header names in the CSV file.
+// Exports with separate money out / money in columns map Debit and Credit
+// instead of Amount.
+type ColumnMapping struct {
+	Category    string `json:"category"`
+	Source      string `json:"source"`
+	Description string `json:"description"`
+	Amount      string `json:"amount"`
+	Debit       string `json:"debit"`
+	Credit      string `json:"credit"`
+	Currency    string `json:"currency"`
+	Paid        string `json:"paid"`
+	Bank        string `json:"bank"`
+	Date        string `json:"date"`
+}
+
+type ImportProfileRequest struct {
+	Name        string        `json:"name"`
+	HeaderRow   int           `json:"headerRow"`
+	Delimiter   string        `json:"delimiter"`
+	Columns     ColumnMapping `json:"columns"`
+	PaidMarker  string        `json:"paidMarker"`
+	DateFormats []string      `json:"dateFormats"`
+	AmountSign  string        `json:"amountSign"`
+}
+
+// ImportLayout records what the parser assumed about an uploaded file
+type ImportLayout struct {
+	Format         string            `json:"format"`
+	Encoding       string            `json:"encoding"`
+	Delimiter      string            `json:"delimiter"`
+	Sheet          string            `json:"sheet,omitempty"`     // workbook sheet
+	ProfileID      string            `json:"profileId,omitempty"` // import profile applied, if any
+	HeaderRow      int               `json:"headerRow"`           // 1-based
+	HeaderDetected bool              `json:"headerDetected"`
+	Columns        map[string]string `json:"columns"` // field -> header name
+}
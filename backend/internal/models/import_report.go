@@ -0,0 +1,45 @@
+package models
+
+const (
+	SeverityError   = "error"   // row was not imported
+	SeverityWarning = "warning" // row was imported, but something was dropped or assumed
+)
+
+type ImportIssue struct {
+	Row      int      `json:"row"` // file line number, 0 for file-level issues
+	Record   []string `json:"record"`
+	Reason   string   `json:"reason"`
+	Severity string   `json:"severity"`
+}
+
+// ImportReport summarises what happened to every data row of an import
+type ImportReport struct {
+	FileID       string `json:"fileId"`
+	TotalRows    int    `json:"totalRows"`
+	ImportedRows int    `json:"importedRows"`
+	SkippedRows  int    `json:"skippedRows"`
+	// Rows matching transactions in other files, handled by the duplicate policy
+	DuplicateRows int           `json:"duplicateRows"`
+	Issues        []ImportIssue `json:"issues"`
+	CreatedAt     int64         `json:"createdAt"`
+}
+
+func (r *ImportReport) AddIssue(row int, record []string, severity, reason string) {
+	r.Issues = append(r.Issues, ImportIssue{
+		Row:      row,
+		Record:   record,
+		Reason:   reason,
+		Severity: severity,
+	})
+}
+
+// ImportPreview is the result of parsing a file without importing it
+type ImportPreview struct {
+	FileName         string          `json:"fileName"`
+	Layout           ImportLayout    `json:"layout"`
+	TransactionCount int             `json:"transactionCount"`
+	Transactions     []Transaction   `json:"transactions"` // first N parsed rows
+	Report           ImportReport    `json:"report"`
+	Categories       []CategoryTotal `json:"categories"`       // in the base currency
+	UnconvertedCount int             `json:"unconvertedCount"` // rows left out of Categories, no exchange rate
+}
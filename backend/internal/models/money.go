@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in minor units (grosze, cents): 1/100 of the currency.
+// It is stored as INTEGER in SQLite and written to JSON as a decimal number,
+// so 1234 is 12.34.
+type Money int64
+
+const moneyScale = 100
+
+var errInvalidMoney = errors.New("invalid amount")
+
+// MoneyFromFloat rounds a float amount, e.g. a spreadsheet cell value, to the
+// nearest minor unit
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * moneyScale))
+}
+
+// ParseMoney parses a plain decimal such as "-1234.5" without going through
+// float64. Digits past the second decimal are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, errInvalidMoney
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, part := range []string{whole, frac} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, errInvalidMoney
+			}
+		}
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/moneyScale-1 {
+		return 0, errInvalidMoney
+	}
+
+	frac += "000"
+	cents, _ := strconv.ParseInt(frac[:2], 10, 64)
+	m := Money(units*moneyScale + cents)
+	if frac[2] >= '5' {
+		m++
+	}
+
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / moneyScale
+}
+
+// String formats the amount with two decimals, e.g. "-12.30"
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+	}
+	abs := uint64(m.Abs())
+	cents := strconv.FormatUint(abs%moneyScale, 10)
+	if len(cents) < 2 {
+		cents = "0" + cents
+	}
+	return sign + strconv.FormatUint(abs/moneyScale, 10) + "." + cents
+}
+
+// MarshalJSON writes the same numbers float amounts used to: 12.3, 49, -0.5
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := strings.TrimRight(m.String(), "0")
+	return []byte(strings.TrimSuffix(s, ".")), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		// Exponent notation such as 1e3
+		f, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil {
+			return err
+		}
+		parsed = MoneyFromFloat(f)
+	}
+	*m = parsed
+	return nil
+}
+
+// MoneyInput is an amount in a request body. Unlike Money it only accepts
+// JSON numbers, so a quoted "12" is an error rather than 12.
+type MoneyInput Money
+
+func (m *MoneyInput) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return errors.New("amount must be a JSON number, not a string")
+	}
+	return (*Money)(m).UnmarshalJSON(data)
+}
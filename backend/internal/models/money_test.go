@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"10", 1000},
+		{"-3", -300},
+		{"+7", 700},
+		{"0", 0},
+		{"10.5", 1050},
+		{"10.50", 1050},
+		{".5", 50},
+		{"-.5", -50},
+		{" 12.34 ", 1234},
+		{"1.004", 100},
+		{"1.005", 101},
+		{"-1.005", -101},
+		{"0.999", 100},
+		{"1234567.891", 123456789},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, in := range []string{"", ".", "-", "abc", "1,5", "1.2.3", "1e3", "--1"} {
+		if got, err := ParseMoney(in); err == nil {
+			t.Errorf("ParseMoney(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestMoneyInputRejectsStrings(t *testing.T) {
+	var req TransactionRequest
+	if err := json.Unmarshal([]byte(`{"amount":12.5}`), &req); err != nil || req.Amount != 1250 {
+		t.Errorf("amount 12.5: got %d, %v", req.Amount, err)
+	}
+	for _, body := range []string{`{"amount":"12"}`, `{"amount":""}`} {
+		var patch TransactionPatch
+		if err := json.Unmarshal([]byte(body), &patch); err == nil {
+			t.Errorf("%s: want an error", body)
+		}
+	}
+}
@@ -5,9 +5,9 @@ type RecurringPattern struct {
 	Source             string   `json:"source"`
 	Category           string   `json:"category"`
 	DescriptionPattern *string  `json:"descriptionPattern"`
-	AvgAmount          float64  `json:"avgAmount"`
-	MinAmount          *float64 `json:"minAmount"`
-	MaxAmount          *float64 `json:"maxAmount"`
+	AvgAmount          Money    `json:"avgAmount"`
+	MinAmount          *Money   `json:"minAmount"`
+	MaxAmount          *Money   `json:"maxAmount"`
 	AmountVariance     *float64 `json:"amountVariance"`
 	Frequency          *string  `json:"frequency"`
 	AvgIntervalDays    *int     `json:"avgIntervalDays"`
@@ -29,9 +29,9 @@ type RecurringPatternWithTransactions struct {
 }
 
 type RecurringSummary struct {
-	TotalMonthly float64 `json:"totalMonthly"`
-	TotalYearly  float64 `json:"totalYearly"`
-	PatternCount int     `json:"patternCount"`
+	TotalMonthly Money `json:"totalMonthly"`
+	TotalYearly  Money `json:"totalYearly"`
+	PatternCount int   `json:"patternCount"`
 }
 
 type RecurringResponse struct {
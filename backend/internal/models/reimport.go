@@ -0,0 +1,20 @@
+package models
+
+// ReimportDiff compares a file's stored transactions with a new upload of it
+type ReimportDiff struct {
+	DryRun    bool                `json:"dryRun"`
+	Added     []Transaction       `json:"added"`
+	Removed   []Transaction       `json:"removed"`
+	Changed   []TransactionChange `json:"changed"`
+	Unchanged int                 `json:"unchanged"`
+}
+
+// TransactionChange is a stored transaction matched to a row of the new
+// upload. Fields lists what the upload changes; fields edited by hand are
+// kept and listed in KeptEdits instead.
+type TransactionChange struct {
+	Before    Transaction `json:"before"`
+	After     Transaction `json:"after"`
+	Fields    []string    `json:"fields"`
+	KeptEdits []string    `json:"keptEdits,omitempty"`
+}
@@ -0,0 +1,98 @@
+package models
+
+// Fields a rule condition can test
+const (
+	RuleFieldSource      = "source"
+	RuleFieldDescription = "description"
+	RuleFieldBank        = "bank"
+	RuleFieldAmount      = "amount" // Min and Max
+	RuleFieldDate        = "date"   // From and To
+)
+
+// Operators of conditions on text fields; all but regex ignore case
+const (
+	RuleOpEquals   = "equals"
+	RuleOpContains = "contains"
+	RuleOpPrefix   = "prefix"
+	RuleOpRegex    = "regex"
+)
+
+// RuleCondition tests one field of a transaction. Text fields use Operator
+// and Value, amount the inclusive range Min-Max and date the inclusive range
+// From-To (YYYY-MM-DD); an open end is left empty.
+type RuleCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Min      *Money `json:"min,omitempty"`
+	Max      *Money `json:"max,omitempty"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+}
+
+// RuleActions are the fields a matching rule sets; nil ones are left as
+// they are
+type RuleActions struct {
+	Category *string  `json:"category,omitempty"`
+	Source   *string  `json:"source,omitempty"`
+	IsPaid   *bool    `json:"isPaid,omitempty"`
+	Tags     []string `json:"tags,omitempty"` // added to those the transaction has
+}
+
+// Rule changes transactions matching all of its conditions, which are
+// tested in order. Rules run by Position; a matching rule with Stop set
+// ends the run for that transaction.
+type Rule struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Position   int             `json:"position"`
+	Enabled    bool            `json:"enabled"`
+	Stop       bool            `json:"stop"`
+	Conditions []RuleCondition `json:"conditions"`
+	Actions    RuleActions     `json:"actions"`
+	CreatedAt  int64           `json:"createdAt"`
+	UpdatedAt  int64           `json:"updatedAt"`
+}
+
+// RuleRequest creates or replaces a rule; Enabled defaults to true. New
+// rules are added after the existing ones.
+type RuleRequest struct {
+	Name       string          `json:"name"`
+	Enabled    *bool           `json:"enabled"`
+	Stop       bool            `json:"stop"`
+	Conditions []RuleCondition `json:"conditions"`
+	Actions    RuleActions     `json:"actions"`
+}
+
+// RuleOrderRequest lists every rule ID in the order the rules should run
+type RuleOrderRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// RuleFieldChange is one field a rule run changed
+type RuleFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// RuleChange is a transaction changed by a rule run, with the rules that
+// matched it
+type RuleChange struct {
+	TransactionID   string            `json:"transactionId"`
+	TransactionDate *string           `json:"transactionDate"`
+	Source          string            `json:"source"`
+	Description     string            `json:"description"`
+	Amount          Money             `json:"amount"`
+	Rules           []string          `json:"rules"`
+	Fields          []RuleFieldChange `json:"fields"`
+}
+
+// RuleApplyResult reports what running the rules over stored transactions
+// changed, or would change on a dry run
+type RuleApplyResult struct {
+	DryRun  bool         `json:"dryRun"`
+	Checked int          `json:"checked"`
+	Updated int          `json:"updated"`
+	Changes []RuleChange `json:"changes"`
+}
@@ -0,0 +1,57 @@
+package models
+
+// How a source alias pattern is matched against a transaction's source
+const (
+	AliasExact  = "exact"  // whole source, ignoring case
+	AliasPrefix = "prefix" // start of the source, ignoring case
+	AliasRegex  = "regex"  // Go regular expression
+)
+
+type SourceAlias struct {
+	Match   string `json:"match"` // AliasExact, AliasPrefix or AliasRegex
+	Pattern string `json:"pattern"`
+}
+
+// Source is a merchant's canonical name and the spellings imported
+// transactions use for it
+type Source struct {
+	ID               string        `json:"id"`
+	Name             string        `json:"name"`
+	Aliases          []SourceAlias `json:"aliases"`
+	TransactionCount int           `json:"transactionCount"`
+	CreatedAt        int64         `json:"createdAt"`
+	UpdatedAt        int64         `json:"updatedAt"`
+}
+
+type SourceRequest struct {
+	Name    string        `json:"name"`
+	Aliases []SourceAlias `json:"aliases"`
+}
+
+// SourceRename is one spelling replaced by a canonical name
+type SourceRename struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// SourceApplyResult reports what applying the aliases to stored
+// transactions changed, or would change on a dry run
+type SourceApplyResult struct {
+	DryRun  bool           `json:"dryRun"`
+	Updated int            `json:"updated"`
+	Renames []SourceRename `json:"renames"`
+}
+
+type SourceCount struct {
+	Source string `json:"source"`
+	Count  int    `json:"count"`
+}
+
+// SourceSuggestion is a group of similar sources that look like one
+// merchant; Name is the most used spelling
+type SourceSuggestion struct {
+	Name       string        `json:"name"`
+	Sources    []SourceCount `json:"sources"`
+	Similarity float64       `json:"similarity"` // lowest between linked spellings, 0-1
+}
@@ -0,0 +1,22 @@
+package models
+
+// Tag is a free-form label for transactions, independent of their category
+type Tag struct {
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	Color            *string `json:"color"` // #rrggbb
+	TransactionCount int     `json:"transactionCount"`
+	CreatedAt        int64   `json:"createdAt"`
+	UpdatedAt        int64   `json:"updatedAt"`
+}
+
+type TagRequest struct {
+	Name  string  `json:"name"`
+	Color *string `json:"color"`
+}
+
+// TransactionTagsRequest names the tags attached to a transaction; unknown
+// names are created
+type TransactionTagsRequest struct {
+	Tags []string `json:"tags"`
+}
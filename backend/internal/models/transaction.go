@@ -1,27 +1,83 @@
 package models
 
+const (
+	DirectionDebit  = "debit"  // money out: expense
+	DirectionCredit = "credit" // money in: income
+)
+
 type Transaction struct {
-	ID              string  `json:"id"`
-	FileID          string  `json:"fileId"`
-	Category        string  `json:"category"`
-	Source          string  `json:"source"`
-	Description     string  `json:"description"`
-	Amount          float64 `json:"amount"`
-	AmountOriginal  string  `json:"amountOriginal"`
-	IsPaid          bool    `json:"isPaid"`
-	Bank            string  `json:"bank"`
-	TransactionDate *string `json:"transactionDate"`
-	CreatedAt       int64   `json:"createdAt"`
+	ID              string   `json:"id"`
+	FileID          string   `json:"fileId"`
+	Category        string   `json:"category"`
+	Source          string   `json:"source"`
+	Description     string   `json:"description"`
+	Amount          Money    `json:"amount"` // always positive, see Direction
+	AmountOriginal  string   `json:"amountOriginal"`
+	Direction       string   `json:"direction"`
+	Currency        string   `json:"currency"`   // ISO 4217 code of Amount
+	AmountBase      *Money   `json:"amountBase"` // Amount in the base currency, nil without a rate
+	IsPaid          bool     `json:"isPaid"`
+	Bank            string   `json:"bank"`
+	TransactionDate *string  `json:"transactionDate"`
+	ExternalID      *string  `json:"externalId"`  // bank-assigned ID, e.g. OFX FITID
+	Fingerprint     string   `json:"-"`           // see Transaction.ComputeFingerprint
+	DuplicateOf     *string  `json:"duplicateOf"` // flagged as a copy of this transaction
+	Tags            []string `json:"tags"`        // tag names, sorted
+	CreatedAt       int64    `json:"createdAt"`
+	DeletedAt       *int64   `json:"deletedAt,omitempty"` // set while in the trash
+}
+
+// TransactionRequest is a transaction entered by hand. Direction defaults to
+// debit, currency to the base currency and the date to today.
+type TransactionRequest struct {
+	Category        string     `json:"category"`
+	Source          string     `json:"source"`
+	Description     string     `json:"description"`
+	Amount          MoneyInput `json:"amount"`
+	Direction       string     `json:"direction"`
+	Currency        string     `json:"currency"`
+	IsPaid          bool       `json:"isPaid"`
+	Bank            string     `json:"bank"`
+	TransactionDate *string    `json:"transactionDate"`
+	Tags            []string   `json:"tags"`
+}
+
+// TransactionPatch is an edit of a transaction; nil fields are left as they
+// are. An empty TransactionDate clears the date.
+type TransactionPatch struct {
+	Category        *string     `json:"category"`
+	Source          *string     `json:"source"`
+	Description     *string     `json:"description"`
+	Amount          *MoneyInput `json:"amount"`
+	Direction       *string     `json:"direction"`
+	Currency        *string     `json:"currency"`
+	IsPaid          *bool       `json:"isPaid"`
+	Bank            *string     `json:"bank"`
+	TransactionDate *string     `json:"transactionDate"`
 }
 
 type TransactionFilter struct {
 	FileIDs           []string
 	FileNames         []string
+	Categories        []string
+	Sources           []string
 	ExcludeCategories []string
 	ExcludeSources    []string
+	Tags              []string // any of them
+	ExcludeTags       []string
 	IsPaid            *bool
 	DateFrom          *string
 	DateTo            *string
+	Direction         *string // DirectionDebit or DirectionCredit
+	// Flagged duplicates are left out unless asked for, so overlapping
+	// imports are not counted twice
+	IncludeDuplicates bool
+}
+
+// BulkPreview is what a bulk edit or delete would touch
+type BulkPreview struct {
+	Count  int           `json:"count"`
+	Sample []Transaction `json:"sample"`
 }
 
 type Pagination struct {
@@ -36,24 +92,47 @@ type PaginatedTransactions struct {
 	Pagination Pagination    `json:"pagination"`
 }
 
+// PaymentSummary splits money out (TotalSpent) from money in. Paid and
+// unpaid amounts and counts cover expenses only.
 type PaymentSummary struct {
-	TotalSpent   float64 `json:"totalSpent"`
-	PaidAmount   float64 `json:"paidAmount"`
-	UnpaidAmount float64 `json:"unpaidAmount"`
-	PaidCount    int     `json:"paidCount"`
-	UnpaidCount  int     `json:"unpaidCount"`
+	Currency         string `json:"currency"` // base currency of all amounts
+	TotalSpent       Money  `json:"totalSpent"`
+	TotalIncome      Money  `json:"totalIncome"`
+	NetCashFlow      Money  `json:"netCashFlow"` // income - expenses
+	PaidAmount       Money  `json:"paidAmount"`
+	UnpaidAmount     Money  `json:"unpaidAmount"`
+	PaidCount        int    `json:"paidCount"`
+	UnpaidCount      int    `json:"unpaidCount"`
+	UnconvertedCount int    `json:"unconvertedCount"` // left out of the amounts, no exchange rate
 }
 
+// CategoryTotal.Total and Percentage are expenses; Income and Net cover
+// credits in the same category
 type CategoryTotal struct {
 	Category   string  `json:"category"`
-	Total      float64 `json:"total"`
+	Total      Money   `json:"total"`
+	Income     Money   `json:"income"`
+	Net        Money   `json:"net"`
+	Count      int     `json:"count"`
+	Percentage float64 `json:"percentage"`
+}
+
+// TagTotal covers the transactions with a tag; one with several tags counts
+// towards each
+type TagTotal struct {
+	Tag        string  `json:"tag"`
+	Total      Money   `json:"total"`
+	Income     Money   `json:"income"`
+	Net        Money   `json:"net"`
 	Count      int     `json:"count"`
 	Percentage float64 `json:"percentage"`
 }
 
 type SourceTotal struct {
 	Source     string  `json:"source"`
-	Total      float64 `json:"total"`
+	Total      Money   `json:"total"`
+	Income     Money   `json:"income"`
+	Net        Money   `json:"net"`
 	Count      int     `json:"count"`
 	Percentage float64 `json:"percentage"`
 }
@@ -0,0 +1,252 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"kiro-finance-backend/internal/models"
+)
+
+// CAMT.053 elements, matched by local name so any schema version works
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	IBAN     string        `xml:"Acct>Id>IBAN"`
+	Other    string        `xml:"Acct>Id>Othr>Id"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtBalance struct {
+	Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtEntry struct {
+	Amount       camtAmount    `xml:"Amt"`
+	Indicator    string        `xml:"CdtDbtInd"`
+	BookingDate  string        `xml:"BookgDt>Dt"`
+	BookingTime  string        `xml:"BookgDt>DtTm"`
+	ValueDate    string        `xml:"ValDt>Dt"`
+	ServicerRef  string        `xml:"AcctSvcrRef"`
+	EntryRef     string        `xml:"NtryRef"`
+	AddtlInfo    string        `xml:"AddtlNtryInf"`
+	Transactions []camtDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtDetails struct {
+	DebtorName      string   `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPartyName string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	CreditorName    string   `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPtyName string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Remittance      []string `xml:"RmtInf>Ustrd"`
+	AddtlInfo       string   `xml:"AddtlTxInf"`
+}
+
+// ParseCAMT053 parses ISO 20022 camt.053 bank-to-customer statements. Each
+// entry becomes one transaction; batch details only supply the counterparty
+// and remittance text.
+func ParseCAMT053(reader io.Reader, fileID string, opts Options) (*Result, error) {
+	data, encoding, err := readUTF8(reader, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// Content is already UTF-8 whatever the declaration says
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var doc camtDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid XML: %w", err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, errors.New("unsupported XML document: no camt.053 BkToCstmrStmt statement found")
+	}
+
+	result := &Result{
+		Layout: models.ImportLayout{
+			Format:   FormatCAMT053,
+			Encoding: encoding,
+			Columns:  map[string]string{},
+		},
+		Report: models.ImportReport{
+			FileID: fileID,
+			Issues: []models.ImportIssue{},
+		},
+	}
+
+	entry := 0
+	for _, stmt := range doc.Statements {
+		account := stmt.IBAN
+		if account == "" {
+			account = stmt.Other
+		}
+
+		for _, bal := range stmt.Balances {
+			amount, ok := camtBalanceAmount(bal)
+			if !ok {
+				continue
+			}
+			switch bal.Code {
+			case "OPBD", "PRCD":
+				// The first statement's opening balance opens the whole file
+				if result.OpeningBalance == nil {
+					result.OpeningBalance = &amount
+				}
+			case "CLBD":
+				result.ClosingBalance = &amount
+			}
+		}
+
+		for _, e := range stmt.Entries {
+			entry++
+			result.Report.TotalRows++
+			if tx := camtTransaction(e, entry, account, fileID, &result.Report); tx != nil {
+				result.Transactions = append(result.Transactions, *tx)
+				result.Report.ImportedRows++
+			} else {
+				result.Report.SkippedRows++
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func camtTransaction(e camtEntry, entry int, account, fileID string, report *models.ImportReport) *models.Transaction {
+	record := []string{
+		"Amt=" + e.Amount.Value + " " + e.Amount.Currency,
+		"CdtDbtInd=" + e.Indicator,
+		"BookgDt=" + e.BookingDate + e.BookingTime,
+	}
+
+	amountOriginal := strings.TrimSpace(e.Amount.Value)
+	amount, err := models.ParseMoney(amountOriginal)
+	if err != nil {
+		report.AddIssue(entry, record, models.SeverityError, fmt.Sprintf("invalid amount %q", amountOriginal))
+		return nil
+	}
+
+	var direction string
+	switch e.Indicator {
+	case "DBIT":
+		direction = models.DirectionDebit
+		amountOriginal = "-" + amountOriginal
+	case "CRDT":
+		direction = models.DirectionCredit
+	default:
+		report.AddIssue(entry, record, models.SeverityError, fmt.Sprintf("invalid CdtDbtInd %q", e.Indicator))
+		return nil
+	}
+
+	var transactionDate *string
+	dateValue := e.BookingDate
+	if dateValue == "" {
+		dateValue = e.BookingTime
+	}
+	if dateValue == "" {
+		dateValue = e.ValueDate
+	}
+	if len(dateValue) >= 10 {
+		if t, err := time.Parse("2006-01-02", dateValue[:10]); err == nil {
+			date := t.Format("2006-01-02")
+			transactionDate = &date
+		}
+	}
+	if transactionDate == nil {
+		report.AddIssue(entry, record, models.SeverityWarning,
+			fmt.Sprintf("unrecognised booking date %q, imported without date", dateValue))
+	}
+
+	var externalID *string
+	if ref := e.ServicerRef; ref != "" {
+		externalID = &ref
+	} else if ref := e.EntryRef; ref != "" {
+		externalID = &ref
+	}
+
+	var source string
+	var texts []string
+	for _, d := range e.Transactions {
+		// The counterparty is whoever is on the other side of the entry
+		name := firstNonEmpty(d.CreditorName, d.CreditorPtyName)
+		if e.Indicator == "CRDT" {
+			name = firstNonEmpty(d.DebtorName, d.DebtorPartyName)
+		}
+		if source == "" {
+			source = strings.TrimSpace(name)
+		}
+		for _, text := range d.Remittance {
+			if text = strings.TrimSpace(text); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		if len(d.Remittance) == 0 && strings.TrimSpace(d.AddtlInfo) != "" {
+			texts = append(texts, strings.TrimSpace(d.AddtlInfo))
+		}
+	}
+
+	description := strings.Join(texts, " ")
+	if description == "" {
+		description = strings.TrimSpace(e.AddtlInfo)
+	}
+	if description == "" {
+		description = source
+	}
+	if source == "" {
+		source = description
+	}
+
+	return &models.Transaction{
+		ID:              uuid.New().String(),
+		FileID:          fileID,
+		Source:          source,
+		Description:     description,
+		Amount:          amount,
+		AmountOriginal:  amountOriginal,
+		Direction:       direction,
+		Currency:        strings.ToUpper(e.Amount.Currency),
+		IsPaid:          true,
+		Bank:            account,
+		TransactionDate: transactionDate,
+		ExternalID:      externalID,
+		CreatedAt:       time.Now().Unix(),
+	}
+}
+
+func camtBalanceAmount(bal camtBalance) (models.Money, bool) {
+	amount, err := models.ParseMoney(bal.Amount.Value)
+	if err != nil {
+		return 0, false
+	}
+	if bal.Indicator == "DBIT" {
+		amount = -amount
+	}
+	return amount, true
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
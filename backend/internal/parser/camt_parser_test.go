@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"kiro-finance-backend/internal/models"
+)
+
+func TestParseCAMT053Amounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    string
+		indicator string
+		want      models.Money
+		direction string
+		original  string
+		skipped   bool
+	}{
+		{"debit", "123.45", "DBIT", 12345, models.DirectionDebit, "-123.45", false},
+		{"credit", "5000", "CRDT", 500000, models.DirectionCredit, "5000", false},
+		{"no indicator", "1.00", "", 0, "", "", true},
+		{"invalid amount", "1,00", "DBIT", 0, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xml := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"><BkToCstmrStmt><Stmt>
+<Acct><Id><IBAN>PL61109010140000071219812874</IBAN></Id></Acct>
+<Ntry><Amt Ccy="eur">` + tt.amount + `</Amt><CdtDbtInd>` + tt.indicator + `</CdtDbtInd><BookgDt><Dt>2024-01-05</Dt></BookgDt>
+<NtryDtls><TxDtls><RltdPties><Dbtr><Nm>Employer</Nm></Dbtr><Cdtr><Nm>Żabka</Nm></Cdtr></RltdPties></TxDtls></NtryDtls></Ntry>
+</Stmt></BkToCstmrStmt></Document>`
+			result, err := ParseCAMT053(strings.NewReader(xml), "f1", Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.skipped {
+				if len(result.Transactions) != 0 || result.Report.SkippedRows != 1 {
+					t.Errorf("want the entry skipped, got %+v", result.Transactions)
+				}
+				return
+			}
+			if len(result.Transactions) != 1 {
+				t.Fatalf("got %d transactions, issues %+v", len(result.Transactions), result.Report.Issues)
+			}
+			tx := result.Transactions[0]
+			if tx.Amount != tt.want || tx.Direction != tt.direction || tx.AmountOriginal != tt.original || tx.Currency != "EUR" {
+				t.Errorf("got %d %s %q %s, want %d %s %q EUR", tx.Amount, tx.Direction, tx.AmountOriginal, tx.Currency, tt.want, tt.direction, tt.original)
+			}
+			// The counterparty is the creditor of a debit and the debtor of a credit
+			wantSource := "Żabka"
+			if tt.indicator == "CRDT" {
+				wantSource = "Employer"
+			}
+			if tx.Source != wantSource {
+				t.Errorf("source = %q, want %q", tx.Source, wantSource)
+			}
+		})
+	}
+}
+
+func TestParseCAMT053Balances(t *testing.T) {
+	xml := `<Document><BkToCstmrStmt><Stmt>
+<Bal><Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp><Amt Ccy="PLN">100.00</Amt><CdtDbtInd>DBIT</CdtDbtInd></Bal>
+<Bal><Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp><Amt Ccy="PLN">876.55</Amt><CdtDbtInd>CRDT</CdtDbtInd></Bal>
+</Stmt></BkToCstmrStmt></Document>`
+	result, err := ParseCAMT053(strings.NewReader(xml), "f1", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OpeningBalance == nil || *result.OpeningBalance != -10000 {
+		t.Errorf("opening balance = %v, want -10000", result.OpeningBalance)
+	}
+	if result.ClosingBalance == nil || *result.ClosingBalance != 87655 {
+		t.Errorf("closing balance = %v, want 87655", result.ClosingBalance)
+	}
+}
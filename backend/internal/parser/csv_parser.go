@@ -1,10 +1,12 @@
 package parser
 
 import (
+	"bytes"
 	"encoding/csv"
+	"fmt"
 	"io"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,109 +14,340 @@ import (
 	"kiro-finance-backend/internal/models"
 )
 
-const (
-	skipRows  = 3
-	headerRow = 4
-)
+const defaultHeaderRow = 4
+
+var defaultDateFormats = []string{
+	"2006-01-02",
+	"02-01-2006",
+	"02/01/2006",
+	"2006/01/02",
+	"02.01.2006",
+}
 
-var currencyRegex = regexp.MustCompile(`[złusdPLNUSDEUR€$]`)
+// Anything but digits, separators, signs and parentheses: currency symbols
+// and codes, spaces, stray text
+var nonNumericRegex = regexp.MustCompile(`[^0-9.,+\-()\x{2212}]`)
 var decimalEndRegex = regexp.MustCompile(`,\d{2}$`)
 
-func ParseCSV(reader io.Reader, fileID string) ([]models.Transaction, error) {
-	csvReader := csv.NewReader(reader)
-	csvReader.FieldsPerRecord = -1 // Allow variable fields
+// DefaultProfile returns the layout of the original spreadsheet export:
+// Polish column names, with the header usually on row 4 after three
+// metadata rows. The header row and delimiter are detected.
+func DefaultProfile() *models.ImportProfile {
+	return &models.ImportProfile{
+		Name: "default",
+		Columns: models.ColumnMapping{
+			Category:    "Rodzaj",
+			Source:      "Skąd",
+			Description: "Co",
+			Amount:      "Za ile",
+			Paid:        "Opłacone?",
+			Bank:        "Bank",
+			Date:        "Data",
+		},
+		PaidMarker:  "✅",
+		DateFormats: defaultDateFormats,
+	}
+}
+
+// ParseCSV parses transactions using the profile in opts. Settings left
+// empty (encoding, header row, delimiter) are detected from the content.
+func ParseCSV(reader io.Reader, fileID string, opts Options) (*Result, error) {
+	profile := opts.Profile
+	if profile == nil {
+		profile = DefaultProfile()
+	}
+
+	data, encoding, err := readUTF8(reader, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
 
-	var transactions []models.Transaction
-	rowNum := 0
-	var headers []string
-	colIndex := make(map[string]int)
+	delimiter := sniffDelimiter(data)
+	if d := []rune(profile.Delimiter); len(d) == 1 {
+		delimiter = d[0]
+	}
+
+	csvReader := csv.NewReader(bytes.NewReader(data))
+	csvReader.Comma = delimiter
+	csvReader.FieldsPerRecord = -1 // Allow variable fields
 
+	var records [][]string
+	var lines []int // file line number of each record
+	var malformed []*csv.ParseError
 	for {
 		record, err := csvReader.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			continue // Skip malformed rows
+			// Skip malformed rows, reported once the header is known
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				malformed = append(malformed, parseErr)
+				continue
+			}
+			return nil, err
 		}
+		line, _ := csvReader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
 
-		rowNum++
+	result := &Result{
+		Layout: models.ImportLayout{
+			Format:    FormatCSV,
+			Encoding:  encoding,
+			Delimiter: string(delimiter),
+			Columns:   map[string]string{},
+		},
+		Report: models.ImportReport{
+			FileID: fileID,
+			Issues: []models.ImportIssue{},
+		},
+	}
+	report := &result.Report
 
-		// Skip metadata rows
-		if rowNum <= skipRows {
+	headerLine, ok := parseTable(&table{records: records, lines: lines}, profile, fileID, result)
+	if !ok {
+		return result, nil
+	}
+
+	rawLines := strings.Split(string(data), "\n")
+	for _, parseErr := range malformed {
+		if parseErr.StartLine <= headerLine {
 			continue
 		}
+		var raw []string
+		if parseErr.StartLine <= len(rawLines) {
+			raw = []string{strings.TrimRight(rawLines[parseErr.StartLine-1], "\r")}
+		}
+		report.TotalRows++
+		report.SkippedRows++
+		report.AddIssue(parseErr.StartLine, raw, models.SeverityError, "malformed CSV row: "+parseErr.Err.Error())
+	}
 
-		// Header row
-		if rowNum == headerRow {
-			headers = record
-			for i, h := range headers {
-				colIndex[strings.TrimSpace(h)] = i
-			}
-			continue
+	// Keep issues in file order; malformed rows were collected separately
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Row < report.Issues[j].Row
+	})
+
+	return result, nil
+}
+
+// table is a grid of cells read from a CSV file or spreadsheet sheet.
+// Spreadsheets also carry native cell values, keyed by record index.
+type table struct {
+	records [][]string
+	lines   []int // file line (or sheet row) number of each record
+	cells   []typedCells
+}
+
+// typedCells holds the natively typed values of a row, by column index
+type typedCells struct {
+	numbers map[int]float64
+	dates   map[int]string // 2006-01-02
+	bools   map[int]bool
+	// currency shown by a number format, e.g. #,##0.00 "zł"
+	currencies map[int]string
+}
+
+// parseTable locates the header, resolves the columns and parses every
+// data row into result. It returns the header line, or false when the
+// header could not be found.
+func parseTable(t *table, profile *models.ImportProfile, fileID string, result *Result) (int, bool) {
+	report := &result.Report
+	records, lines := t.records, t.lines
+	result.Layout.ProfileID = profile.ID
+
+	// Locate the header: fixed by the profile, detected, or the default row
+	headerIdx := -1
+	if profile.HeaderRow < 1 {
+		headerIdx = findHeaderRow(records, profile.Columns)
+		result.Layout.HeaderDetected = headerIdx >= 0
+	}
+	if headerIdx < 0 {
+		headerRow := profile.HeaderRow
+		if headerRow < 1 {
+			headerRow = defaultHeaderRow
 		}
+		headerIdx = recordAtLine(lines, headerRow)
+	}
+	if headerIdx < 0 {
+		report.AddIssue(0, nil, models.SeverityError, "header row not found")
+		return 0, false
+	}
+	headerLine := lines[headerIdx]
+	result.Layout.HeaderRow = headerLine
+	if profile.HeaderRow < 1 && !result.Layout.HeaderDetected {
+		report.AddIssue(headerLine, records[headerIdx], models.SeverityWarning,
+			fmt.Sprintf("header row not detected, assumed row %d", headerLine))
+	}
 
-		// Data rows
+	colIndex, headerNames := resolveColumns(records[headerIdx], profile.Columns)
+	result.Layout.Columns = headerNames
+	_, hasAmount := colIndex["amount"]
+	_, hasDebit := colIndex["debit"]
+	_, hasCredit := colIndex["credit"]
+	if !hasAmount && !hasDebit && !hasCredit {
+		report.AddIssue(headerLine, records[headerIdx], models.SeverityError, "amount column not found in header")
+	}
+
+	for i := headerIdx + 1; i < len(records); i++ {
+		record := records[i]
 		if len(record) == 0 || isEmptyRow(record) {
 			continue
 		}
 
-		tx := parseRow(record, colIndex, fileID)
+		var cells typedCells
+		if i < len(t.cells) {
+			cells = t.cells[i]
+		}
+
+		report.TotalRows++
+		tx := parseRow(record, cells, lines[i], colIndex, profile, fileID, report)
 		if tx != nil {
-			transactions = append(transactions, *tx)
+			result.Transactions = append(result.Transactions, *tx)
+			report.ImportedRows++
+		} else {
+			report.SkippedRows++
 		}
 	}
 
-	return transactions, nil
+	return headerLine, true
 }
 
-func parseRow(record []string, colIndex map[string]int, fileID string) *models.Transaction {
-	getValue := func(key string) string {
-		if idx, ok := colIndex[key]; ok && idx < len(record) {
+// parseRow converts a data row into a transaction, or returns nil when the
+// row cannot be imported. Problems are recorded in the report. Native cell
+// values, when present, take precedence over the text.
+func parseRow(record []string, cells typedCells, line int, colIndex map[string]int, profile *models.ImportProfile, fileID string, report *models.ImportReport) *models.Transaction {
+	getValue := func(field string) string {
+		if idx, ok := colIndex[field]; ok && idx < len(record) {
 			return strings.TrimSpace(record[idx])
 		}
 		return ""
 	}
 
-	amountOriginal := getValue("Za ile")
-	amount := normalizeAmount(amountOriginal)
+	getAmount := func(field string) *models.Money {
+		if idx, ok := colIndex[field]; ok {
+			if n, ok := cells.numbers[idx]; ok {
+				amount := models.MoneyFromFloat(n)
+				return &amount
+			}
+		}
+		return normalizeAmount(getValue(field))
+	}
+
+	// A signed amount column, or separate debit and credit columns
+	var amount *models.Money
+	var direction string
+	amountField := "amount"
+	amountOriginal := getValue("amount")
+	if amountOriginal != "" {
+		amount = getAmount("amount")
+		if amount != nil {
+			direction = directionFromSign(*amount, profile.AmountSign)
+		}
+	} else {
+		debitOriginal, creditOriginal := getValue("debit"), getValue("credit")
+		debit, credit := getAmount("debit"), getAmount("credit")
+		switch {
+		case debit != nil && (*debit != 0 || credit == nil || *credit == 0):
+			amountOriginal, amount, direction = debitOriginal, debit, models.DirectionDebit
+			amountField = "debit"
+		case credit != nil:
+			amountOriginal, amount, direction = creditOriginal, credit, models.DirectionCredit
+			amountField = "credit"
+		case debitOriginal != "":
+			amountOriginal = debitOriginal
+		default:
+			amountOriginal = creditOriginal
+		}
+	}
+	if amountOriginal == "" {
+		report.AddIssue(line, record, models.SeverityError, "missing amount")
+		return nil
+	}
 	if amount == nil {
+		report.AddIssue(line, record, models.SeverityError, fmt.Sprintf("invalid amount %q", amountOriginal))
 		return nil
 	}
 
-	isPaid := strings.Contains(getValue("Opłacone?"), "✅")
+	currency := detectCurrency(amountOriginal)
+	if idx, ok := colIndex[amountField]; ok && currency == "" {
+		currency = cells.currencies[idx]
+	}
+	if value := getValue("currency"); value != "" {
+		if currency = normalizeCurrency(value); currency == "" {
+			report.AddIssue(line, record, models.SeverityWarning, fmt.Sprintf("unrecognised currency %q", value))
+		}
+	}
+
+	isPaid := isPaidValue(getValue("paid"), profile.PaidMarker)
+	if idx, ok := colIndex["paid"]; ok {
+		if b, ok := cells.bools[idx]; ok {
+			isPaid = b
+		}
+	}
 
 	var transactionDate *string
-	if dateStr := getValue("Data"); dateStr != "" {
-		if parsed := parseDate(dateStr); parsed != "" {
+	if idx, ok := colIndex["date"]; ok && cells.dates[idx] != "" {
+		date := cells.dates[idx]
+		transactionDate = &date
+	} else if dateStr := getValue("date"); dateStr != "" {
+		if parsed := parseDate(dateStr, profile.DateFormats); parsed != "" {
 			transactionDate = &parsed
+		} else {
+			report.AddIssue(line, record, models.SeverityWarning,
+				fmt.Sprintf("unrecognised date %q, imported without date", dateStr))
 		}
 	}
 
 	return &models.Transaction{
 		ID:              uuid.New().String(),
 		FileID:          fileID,
-		Category:        getValue("Rodzaj"),
-		Source:          getValue("Skąd"),
-		Description:     getValue("Co"),
-		Amount:          *amount,
+		Category:        getValue("category"),
+		Source:          getValue("source"),
+		Description:     getValue("description"),
+		Amount:          amount.Abs(),
 		AmountOriginal:  amountOriginal,
+		Direction:       direction,
+		Currency:        currency,
 		IsPaid:          isPaid,
-		Bank:            getValue("Bank"),
+		Bank:            getValue("bank"),
 		TransactionDate: transactionDate,
 		CreatedAt:       time.Now().Unix(),
 	}
 }
 
-func normalizeAmount(value string) *float64 {
+// directionFromSign reads the direction of a signed amount under the
+// profile's sign convention
+func directionFromSign(amount models.Money, sign string) string {
+	expenseNegative := sign == models.AmountSignExpenseNegative
+	if (amount < 0) == expenseNegative {
+		return models.DirectionDebit
+	}
+	return models.DirectionCredit
+}
+
+// normalizeAmount parses a formatted amount. Negative amounts may be
+// written with a leading or trailing minus or in parentheses.
+func normalizeAmount(value string) *models.Money {
 	if value == "" {
 		return nil
 	}
 
 	// Remove currency symbols and whitespace
-	cleaned := currencyRegex.ReplaceAllString(value, "")
-	cleaned = strings.ReplaceAll(cleaned, " ", "")
-	cleaned = strings.TrimSpace(cleaned)
+	cleaned := nonNumericRegex.ReplaceAllString(value, "")
+
+	negative := false
+	if strings.HasPrefix(cleaned, "(") && strings.HasSuffix(cleaned, ")") {
+		negative = true
+		cleaned = strings.TrimSpace(cleaned[1 : len(cleaned)-1])
+	}
+	cleaned = strings.Replace(cleaned, "\u2212", "-", 1)
+	if strings.HasSuffix(cleaned, "-") {
+		negative = true
+		cleaned = strings.TrimSuffix(cleaned, "-")
+	}
 
 	if cleaned == "" {
 		return nil
@@ -147,21 +380,29 @@ func normalizeAmount(value string) *float64 {
 		}
 	}
 
-	parsed, err := strconv.ParseFloat(cleaned, 64)
+	parsed, err := models.ParseMoney(cleaned)
 	if err != nil {
 		return nil
 	}
+	if negative {
+		parsed = -parsed.Abs()
+	}
 
 	return &parsed
 }
 
-func parseDate(dateStr string) string {
-	formats := []string{
-		"2006-01-02",
-		"02-01-2006",
-		"02/01/2006",
-		"2006/01/02",
-		"02.01.2006",
+// isPaidValue reports whether the paid column contains the marker. Without
+// a marker any non-empty value counts as paid.
+func isPaidValue(value, marker string) bool {
+	if marker == "" {
+		return value != ""
+	}
+	return strings.Contains(value, marker)
+}
+
+func parseDate(dateStr string, formats []string) string {
+	if len(formats) == 0 {
+		formats = defaultDateFormats
 	}
 
 	for _, format := range formats {
@@ -173,6 +414,16 @@ func parseDate(dateStr string) string {
 	return ""
 }
 
+// recordAtLine returns the index of the record starting on the given line
+func recordAtLine(lines []int, line int) int {
+	for i, l := range lines {
+		if l == line {
+			return i
+		}
+	}
+	return -1
+}
+
 func isEmptyRow(record []string) bool {
 	for _, cell := range record {
 		if strings.TrimSpace(cell) != "" {
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"kiro-finance-backend/internal/models"
+)
+
+var (
+	currencyCodeRegex = regexp.MustCompile(`\b[A-Z]{3}\b`)
+	// NBP table columns such as 1USD or 100HUF: units quoted, then the code
+	rateColumnRegex = regexp.MustCompile(`^(\d+)([A-Z]{3})$`)
+)
+
+// Symbols and local spellings of currencies, checked before ISO codes
+var currencySymbols = []struct{ symbol, code string }{
+	{"zł", "PLN"},
+	{"zl", "PLN"},
+	{"€", "EUR"},
+	{"£", "GBP"},
+	{"$", "USD"},
+}
+
+// detectCurrency returns the ISO code written next to an amount, such as
+// "49,00 zł", "€49" or "49 EUR", or "" when there is none
+func detectCurrency(value string) string {
+	if code := currencyCodeRegex.FindString(strings.ToUpper(value)); code != "" {
+		return code
+	}
+	lower := strings.ToLower(value)
+	for _, s := range currencySymbols {
+		if strings.Contains(lower, s.symbol) {
+			return s.code
+		}
+	}
+	return ""
+}
+
+// normalizeCurrency validates a currency column value as an ISO code
+func normalizeCurrency(value string) string {
+	if code := strings.ToUpper(strings.TrimSpace(value)); len(code) == 3 && currencyCodeRegex.MatchString(code) {
+		return code
+	}
+	return detectCurrency(value)
+}
+
+// ParseExchangeRates reads an NBP table A archive CSV: a header row starting
+// with "data" and one column per currency (1USD, 100HUF, ...), then one row
+// per day with the date as yyyyMMdd. Rates are divided by the quoted units.
+func ParseExchangeRates(reader io.Reader) ([]models.ExchangeRate, error) {
+	data, _, err := readUTF8(reader, "")
+	if err != nil {
+		return nil, err
+	}
+
+	csvReader := csv.NewReader(bytes.NewReader(data))
+	csvReader.Comma = sniffDelimiter(data)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+
+	type rateColumn struct {
+		code  string
+		units float64
+	}
+	var columns map[int]rateColumn
+	var rates []models.ExchangeRate
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 {
+			continue
+		}
+		first := normalizeHeader(record[0])
+
+		if first == "data" || first == "date" {
+			columns = make(map[int]rateColumn)
+			for i, cell := range record[1:] {
+				m := rateColumnRegex.FindStringSubmatch(strings.TrimSpace(cell))
+				if m == nil {
+					continue
+				}
+				units, _ := strconv.ParseFloat(m[1], 64)
+				if units > 0 {
+					columns[i+1] = rateColumn{code: m[2], units: units}
+				}
+			}
+			continue
+		}
+
+		// Table numbers, currency names and other trailing rows are skipped
+		if columns == nil {
+			continue
+		}
+		date, err := time.Parse("20060102", first)
+		if err != nil {
+			if date, err = time.Parse("2006-01-02", first); err != nil {
+				continue
+			}
+		}
+
+		for i, col := range columns {
+			if i >= len(record) {
+				continue
+			}
+			// Rates have four decimals, so "4,3434" is never a thousands group
+			rate, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(record[i]), ",", ".", 1), 64)
+			if err != nil || rate <= 0 {
+				continue
+			}
+			rates = append(rates, models.ExchangeRate{
+				Currency: col.code,
+				Date:     date.Format("2006-01-02"),
+				Rate:     rate / col.units,
+			})
+		}
+	}
+
+	if columns == nil {
+		return nil, errors.New("not an NBP rates file: header row starting with \"data\" not found")
+	}
+	return rates, nil
+}
@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"kiro-finance-backend/internal/models"
+)
+
+const (
+	sniffLines     = 20 // lines inspected when guessing the delimiter
+	headerScanRows = 20 // rows scored when looking for the header
+	minHeaderScore = 2  // matched columns needed to accept a header row
+)
+
+var delimiterCandidates = []rune{';', ',', '\t'}
+
+// Transaction fields that can be mapped to columns, in resolution order
+var fieldNames = []string{"category", "source", "description", "amount", "debit", "credit", "currency", "paid", "bank", "date"}
+
+// columnAliases lists header names recognised for each field regardless of
+// the profile, so English copies and common bank exports map without one.
+var columnAliases = map[string][]string{
+	"category":    {"rodzaj", "kategoria", "category"},
+	"source":      {"skąd", "sklep", "odbiorca", "kontrahent", "nadawca / odbiorca", "source", "merchant", "payee"},
+	"description": {"co", "opis", "tytuł", "opis operacji", "description", "title", "memo", "details"},
+	"amount":      {"za ile", "kwota", "kwota operacji", "amount", "value"},
+	"debit":       {"obciążenia", "wypływy", "debit", "withdrawal", "withdrawals", "money out", "paid out"},
+	"credit":      {"uznania", "wpływy", "credit", "deposit", "deposits", "money in", "paid in"},
+	"currency":    {"waluta", "currency", "ccy"},
+	"paid":        {"opłacone", "paid"},
+	"bank":        {"bank", "konto", "account"},
+	"date":        {"data", "data operacji", "data transakcji", "data księgowania", "date", "transaction date", "booking date"},
+}
+
+// sniffDelimiter picks the candidate that splits the first lines into the
+// same non-zero number of fields most consistently. Separators inside
+// quoted fields are ignored.
+func sniffDelimiter(data []byte) rune {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() && len(lines) < sniffLines {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	best := ','
+	bestScore := 0
+	for _, candidate := range delimiterCandidates {
+		counts := make(map[int]int)
+		for _, line := range lines {
+			if n := countUnquoted(line, candidate); n > 0 {
+				counts[n]++
+			}
+		}
+
+		// Score is the number of lines sharing the most common field count,
+		// weighted slightly by the count itself to prefer richer splits
+		score := 0
+		for n, lineCount := range counts {
+			if s := lineCount*100 + n; s > score {
+				score = s
+			}
+		}
+
+		if score > bestScore {
+			best = candidate
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+func countUnquoted(line string, sep rune) int {
+	count := 0
+	inQuotes := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == sep && !inQuotes:
+			count++
+		}
+	}
+	return count
+}
+
+// findHeaderRow returns the index of the record that matches the most known
+// column names, or -1 when no row matches at least minHeaderScore columns.
+func findHeaderRow(records [][]string, columns models.ColumnMapping) int {
+	best := -1
+	bestScore := minHeaderScore - 1
+
+	for i, record := range records {
+		if i >= headerScanRows {
+			break
+		}
+
+		matched, _ := resolveColumns(record, columns)
+		if len(matched) > bestScore {
+			best = i
+			bestScore = len(matched)
+		}
+	}
+
+	return best
+}
+
+// resolveColumns maps field names to column indexes in the header, trying
+// the profile's header name first and the built-in aliases second. It also
+// returns the header text used for each field.
+func resolveColumns(header []string, columns models.ColumnMapping) (map[string]int, map[string]string) {
+	configured := map[string]string{
+		"category":    columns.Category,
+		"source":      columns.Source,
+		"description": columns.Description,
+		"amount":      columns.Amount,
+		"debit":       columns.Debit,
+		"credit":      columns.Credit,
+		"currency":    columns.Currency,
+		"paid":        columns.Paid,
+		"bank":        columns.Bank,
+		"date":        columns.Date,
+	}
+
+	normalized := make([]string, len(header))
+	for i, h := range header {
+		normalized[i] = normalizeHeader(h)
+	}
+
+	indexOf := func(name string) int {
+		name = normalizeHeader(name)
+		if name == "" {
+			return -1
+		}
+		for i, h := range normalized {
+			if h == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	colIndex := make(map[string]int)
+	headerNames := make(map[string]string)
+	used := make(map[int]bool)
+
+	for _, field := range fieldNames {
+		idx := indexOf(configured[field])
+		for _, alias := range columnAliases[field] {
+			if idx >= 0 && !used[idx] {
+				break
+			}
+			idx = indexOf(alias)
+		}
+		if idx >= 0 && !used[idx] {
+			colIndex[field] = idx
+			headerNames[field] = strings.TrimSpace(header[idx])
+			used[idx] = true
+		}
+	}
+
+	return colIndex, headerNames
+}
+
+func normalizeHeader(h string) string {
+	h = strings.TrimPrefix(h, "\ufeff")
+	h = strings.ToLower(strings.TrimSpace(h))
+	return strings.TrimRight(h, "?:")
+}
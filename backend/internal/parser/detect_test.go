@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"testing"
+
+	"kiro-finance-backend/internal/models"
+)
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want rune
+	}{
+		{"semicolon", "Data;Kwota;Opis\n05.01.2024;12,50;Chleb\n06.01.2024;7,00;Mleko\n", ';'},
+		{"comma", "Date,Amount,Memo\n2024-01-05,12.50,Bread\n2024-01-06,7.00,Milk\n", ','},
+		{"tab", "Date\tAmount\tMemo\n2024-01-05\t12.50\tBread\n", '\t'},
+		{"quoted commas", "Data;Kwota;Opis\n05.01.2024;\"1,50\";\"a, b, c\"\n06.01.2024;\"2,00\";\"d, e\"\n", ';'},
+		{"title lines", "Wydatki\n\n\nRodzaj;Skąd;Co;Za ile\nJedzenie;Biedronka;Zakupy;\"12,50 zł\"\n", ';'},
+		{"single column", "amount\n12\n", ','},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sniffDelimiter([]byte(tt.data)); got != tt.want {
+				t.Errorf("sniffDelimiter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindHeaderRow(t *testing.T) {
+	tests := []struct {
+		name    string
+		records [][]string
+		columns models.ColumnMapping
+		want    int
+	}{
+		{
+			name:    "polish header",
+			records: [][]string{{"Rodzaj", "Skąd", "Co", "Za ile"}, {"Jedzenie", "Biedronka", "Zakupy", "12,50"}},
+			want:    0,
+		},
+		{
+			name:    "after title and blank rows",
+			records: [][]string{{"Wydatki"}, {""}, {"Data operacji", "Kwota", "Opis operacji"}, {"05.01.2024", "12,50", "Chleb"}},
+			want:    2,
+		},
+		{
+			name:    "english header, any case",
+			records: [][]string{{"Exported 2024-01-31"}, {"DATE", "Payee", "Amount"}},
+			want:    1,
+		},
+		{
+			name:    "profile header names",
+			records: [][]string{{"Kiedy", "Ile", "Gdzie"}, {"05.01.2024", "12,50", "Sklep"}},
+			columns: models.ColumnMapping{Date: "Kiedy", Amount: "Ile", Source: "Gdzie"},
+			want:    0,
+		},
+		{
+			name:    "one known column is not a header",
+			records: [][]string{{"Kwota", "x", "y"}, {"12,50", "a", "b"}},
+			want:    -1,
+		},
+		{
+			name:    "no header",
+			records: [][]string{{"05.01.2024", "12,50", "Chleb"}},
+			want:    -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findHeaderRow(tt.records, tt.columns); got != tt.want {
+				t.Errorf("findHeaderRow() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
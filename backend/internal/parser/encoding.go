@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingWindows1250 = "windows-1250"
+	EncodingISO88592    = "iso-8859-2"
+	EncodingIBM852      = "ibm852" // DOS Latin 2, still used by some MT940 exports
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var encodingAliases = map[string]string{
+	"utf-8":        EncodingUTF8,
+	"utf8":         EncodingUTF8,
+	"windows-1250": EncodingWindows1250,
+	"cp1250":       EncodingWindows1250,
+	"win1250":      EncodingWindows1250,
+	"iso-8859-2":   EncodingISO88592,
+	"iso8859-2":    EncodingISO88592,
+	"latin2":       EncodingISO88592,
+	"ibm852":       EncodingIBM852,
+	"cp852":        EncodingIBM852,
+}
+
+// Polish letters that sit on different bytes in the two single-byte
+// encodings (Ą ą Ś ś Ź ź). Bytes 0x80-0x9F never occur in ISO-8859-2 text.
+var (
+	iso88592Letters    = []byte{0xA1, 0xB1, 0xA6, 0xB6, 0xAC, 0xBC}
+	windows1250Letters = []byte{0xA5, 0xB9, 0x8C, 0x9C, 0x8F, 0x9F}
+)
+
+// NormalizeEncoding maps a user-supplied encoding name to one of the
+// supported encodings
+func NormalizeEncoding(name string) (string, error) {
+	if enc, ok := encodingAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return enc, nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q (use utf-8, windows-1250, iso-8859-2 or ibm852)", name)
+}
+
+// DetectEncoding guesses the encoding of raw file bytes. Valid UTF-8 (with
+// or without BOM) wins; otherwise the Polish letter bytes decide between
+// Windows-1250 and ISO-8859-2, defaulting to Windows-1250.
+func DetectEncoding(data []byte) string {
+	if bytes.HasPrefix(data, utf8BOM) || utf8.Valid(data) {
+		return EncodingUTF8
+	}
+
+	isoScore, winScore := 0, 0
+	for _, b := range data {
+		if b >= 0x80 && b <= 0x9F {
+			return EncodingWindows1250
+		}
+		if bytes.IndexByte(iso88592Letters, b) >= 0 {
+			isoScore++
+		}
+		if bytes.IndexByte(windows1250Letters, b) >= 0 {
+			winScore++
+		}
+	}
+
+	if isoScore > winScore {
+		return EncodingISO88592
+	}
+	return EncodingWindows1250
+}
+
+// readUTF8 reads the whole upload and transcodes it to UTF-8, detecting
+// the encoding unless one is given. It returns the encoding used.
+func readUTF8(reader io.Reader, encoding string) ([]byte, string, error) {
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if encoding == "" {
+		encoding = DetectEncoding(raw)
+	}
+	data, err := toUTF8(raw, encoding)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, encoding, nil
+}
+
+// toUTF8 transcodes data from the given encoding and drops a UTF-8 BOM
+func toUTF8(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingWindows1250:
+		return charmap.Windows1250.NewDecoder().Bytes(data)
+	case EncodingISO88592:
+		return charmap.ISO8859_2.NewDecoder().Bytes(data)
+	case EncodingIBM852:
+		return charmap.CodePage852.NewDecoder().Bytes(data)
+	default:
+		return bytes.TrimPrefix(data, utf8BOM), nil
+	}
+}
@@ -0,0 +1,54 @@
+package parser
+
+import "testing"
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+		text string // data in UTF-8
+	}{
+		{"ascii", []byte("Date,Amount"), EncodingUTF8, "Date,Amount"},
+		{"utf-8", []byte("Śląsk źródło ąę"), EncodingUTF8, "Śląsk źródło ąę"},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, "Opłata"...), EncodingUTF8, "Opłata"},
+		{"windows-1250", []byte{0x8C, 0x6C, 0xB9, 0x73, 0x6B, 0x20, 0x9F, 0x72, 0xF3, 0x64, 0xB3, 0x6F, 0x20, 0xB9, 0xEA}, EncodingWindows1250, "Śląsk źródło ąę"},
+		{"iso-8859-2", []byte{0xA6, 0x6C, 0xB1, 0x73, 0x6B, 0x20, 0xBC, 0x72, 0xF3, 0x64, 0xB3, 0x6F, 0x20, 0xB1, 0xEA}, EncodingISO88592, "Śląsk źródło ąę"},
+		// ł has the same byte in both, so the default wins
+		{"ambiguous", []byte{0x4F, 0x70, 0xB3, 0x61, 0x74, 0x61}, EncodingWindows1250, "Opłata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := DetectEncoding(tt.data)
+			if enc != tt.want {
+				t.Fatalf("DetectEncoding() = %s, want %s", enc, tt.want)
+			}
+			got, err := toUTF8(tt.data, enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.text {
+				t.Errorf("toUTF8() = %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestNormalizeEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"UTF8", EncodingUTF8, false},
+		{" cp1250 ", EncodingWindows1250, false},
+		{"Latin2", EncodingISO88592, false},
+		{"koi8-r", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeEncoding(tt.name)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeEncoding(%q) = %q, %v", tt.name, got, err)
+		}
+	}
+}
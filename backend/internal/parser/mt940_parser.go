@@ -0,0 +1,262 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"kiro-finance-backend/internal/models"
+)
+
+var (
+	mt940TagRegex = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+	// :61: value date, optional entry date, mark (C, D, RC, RD), optional
+	// funds code, amount, then transaction type and references
+	mt940LineRegex = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d*)(.*)$`)
+	// :60F:/:62F: mark, date, currency, amount
+	mt940BalanceRegex = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)`)
+)
+
+type mt940Field struct {
+	tag   string
+	value string
+	line  int
+}
+
+type mt940Entry struct {
+	statement string // :61:
+	info      string // :86:
+	currency  string // of the statement's opening balance
+	line      int
+}
+
+// ParseMT940 parses SWIFT MT940 statements, including the structured :86:
+// layout Polish banks use (~20-~25 remittance, ~32-~33 counterparty).
+func ParseMT940(reader io.Reader, fileID string, opts Options) (*Result, error) {
+	data, encoding, err := readUTF8(reader, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := splitMT940Fields(string(data))
+	if len(fields) == 0 {
+		return nil, errors.New("not an MT940 file: no :NN: fields found")
+	}
+
+	result := &Result{
+		Layout: models.ImportLayout{
+			Format:   FormatMT940,
+			Encoding: encoding,
+			Columns:  map[string]string{},
+		},
+		Report: models.ImportReport{
+			FileID: fileID,
+			Issues: []models.ImportIssue{},
+		},
+	}
+
+	var account, currency string
+	var entries []mt940Entry
+
+	for _, f := range fields {
+		switch f.tag {
+		case "25":
+			account = strings.TrimPrefix(strings.TrimSpace(f.value), "/")
+		case "60F", "60M":
+			// The first opening balance of the file opens the whole period
+			if balance, code, ok := parseMT940Balance(f.value); ok {
+				if result.OpeningBalance == nil {
+					result.OpeningBalance = &balance
+				}
+				currency = code
+			}
+		case "62F", "62M":
+			if balance, _, ok := parseMT940Balance(f.value); ok {
+				result.ClosingBalance = &balance
+			}
+		case "61":
+			entries = append(entries, mt940Entry{statement: f.value, currency: currency, line: f.line})
+		case "86":
+			if n := len(entries); n > 0 && entries[n-1].info == "" {
+				entries[n-1].info = f.value
+			}
+		}
+	}
+
+	for _, e := range entries {
+		result.Report.TotalRows++
+		if tx := mt940Transaction(e, account, fileID, &result.Report); tx != nil {
+			result.Transactions = append(result.Transactions, *tx)
+			result.Report.ImportedRows++
+		} else {
+			result.Report.SkippedRows++
+		}
+	}
+
+	return result, nil
+}
+
+// splitMT940Fields groups lines into :NN: fields, joining continuation
+// lines with newlines and dropping SWIFT block wrappers.
+func splitMT940Fields(content string) []mt940Field {
+	var fields []mt940Field
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "-" || trimmed == "-}" || strings.HasPrefix(trimmed, "{") {
+			continue
+		}
+
+		if m := mt940TagRegex.FindStringSubmatch(line); m != nil {
+			fields = append(fields, mt940Field{tag: m[1], value: line[len(m[0]):], line: i + 1})
+			continue
+		}
+
+		if n := len(fields); n > 0 {
+			fields[n-1].value += "\n" + line
+		}
+	}
+
+	return fields
+}
+
+func mt940Transaction(e mt940Entry, account, fileID string, report *models.ImportReport) *models.Transaction {
+	record := []string{":61:" + e.statement}
+	if e.info != "" {
+		record = append(record, ":86:"+e.info)
+	}
+
+	firstLine := strings.SplitN(e.statement, "\n", 2)[0]
+	m := mt940LineRegex.FindStringSubmatch(firstLine)
+	if m == nil {
+		report.AddIssue(e.line, record, models.SeverityError, "unrecognised :61: statement line")
+		return nil
+	}
+
+	valueDate, err := time.Parse("060102", m[1])
+	if err != nil {
+		report.AddIssue(e.line, record, models.SeverityError, fmt.Sprintf("invalid value date %q", m[1]))
+		return nil
+	}
+	bookingDate := valueDate
+	if m[2] != "" {
+		// Entry date is MMDD in the value date's year, or across New Year
+		if entry, err := time.Parse("20060102", valueDate.Format("2006")+m[2]); err == nil {
+			if diff := entry.Sub(valueDate).Hours() / 24; diff > 180 {
+				entry = entry.AddDate(-1, 0, 0)
+			} else if diff < -180 {
+				entry = entry.AddDate(1, 0, 0)
+			}
+			bookingDate = entry
+		}
+	}
+
+	amountOriginal := m[5]
+	amount, err := models.ParseMoney(strings.Replace(amountOriginal, ",", ".", 1))
+	if err != nil {
+		report.AddIssue(e.line, record, models.SeverityError, fmt.Sprintf("invalid amount %q", amountOriginal))
+		return nil
+	}
+
+	// Debits and reversed credits take money out of the account
+	direction := models.DirectionDebit
+	if mark := m[3]; mark == "C" || mark == "RD" {
+		direction = models.DirectionCredit
+		amountOriginal = "+" + amountOriginal
+	} else {
+		amountOriginal = "-" + amountOriginal
+	}
+
+	var externalID *string
+	if idx := strings.Index(m[6], "//"); idx >= 0 {
+		if ref := strings.TrimSpace(m[6][idx+2:]); ref != "" {
+			externalID = &ref
+		}
+	}
+
+	source, description := parseMT940Info(e.info)
+	if source == "" {
+		source = description
+	}
+	if description == "" {
+		description = source
+	}
+
+	date := bookingDate.Format("2006-01-02")
+	return &models.Transaction{
+		ID:              uuid.New().String(),
+		FileID:          fileID,
+		Source:          source,
+		Description:     description,
+		Amount:          amount,
+		AmountOriginal:  amountOriginal,
+		Direction:       direction,
+		Currency:        e.currency,
+		IsPaid:          true,
+		Bank:            account,
+		TransactionDate: &date,
+		ExternalID:      externalID,
+		CreatedAt:       time.Now().Unix(),
+	}
+}
+
+// parseMT940Info splits a :86: field into counterparty name and remittance
+// information. Structured fields start with a 3-digit code followed by the
+// subfield separator (~, ^, < or ?); anything else is free text.
+func parseMT940Info(info string) (name, remittance string) {
+	info = strings.TrimSpace(info)
+	if len(info) < 4 || !isDigits(info[:3]) || !strings.ContainsRune("~^<?", rune(info[3])) {
+		return "", strings.Join(strings.Fields(info), " ")
+	}
+
+	// Subfields may wrap across lines at any character
+	joined := strings.ReplaceAll(strings.ReplaceAll(info, "\r", ""), "\n", "")
+	var names, texts []string
+	for _, part := range strings.Split(joined[4:], string(info[3])) {
+		if len(part) < 2 || !isDigits(part[:2]) {
+			continue
+		}
+		code, value := part[:2], strings.TrimSpace(part[2:])
+		if value == "" {
+			continue
+		}
+		switch {
+		case code == "32" || code == "33":
+			names = append(names, value)
+		case code >= "20" && code <= "29", code >= "60" && code <= "63":
+			texts = append(texts, value)
+		}
+	}
+
+	return strings.Join(names, " "), strings.Join(texts, " ")
+}
+
+// parseMT940Balance returns the signed balance and its currency
+func parseMT940Balance(value string) (models.Money, string, bool) {
+	m := mt940BalanceRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, "", false
+	}
+	amount, err := models.ParseMoney(strings.Replace(m[4], ",", ".", 1))
+	if err != nil {
+		return 0, "", false
+	}
+	if m[1] == "D" {
+		amount = -amount
+	}
+	return amount, m[3], true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"kiro-finance-backend/internal/models"
+)
+
+func TestParseMT940Amounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string // :61: content
+		amount    models.Money
+		direction string
+		original  string
+	}{
+		{"debit", "2401050105D123,45NTRFNONREF//REF1", 12345, models.DirectionDebit, "-123,45"},
+		{"credit", "2401100110C5000,NTRFNONREF//REF2", 500000, models.DirectionCredit, "+5000,"},
+		{"reversed debit", "240112RD10,5NTRFNONREF", 1050, models.DirectionCredit, "+10,5"},
+		{"reversed credit", "240112RC7,00NTRFNONREF", 700, models.DirectionDebit, "-7,00"},
+		{"funds code", "240112DN1,00NTRFNONREF", 100, models.DirectionDebit, "-1,00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sta := ":20:ST1\n:25:/PL61109010140000071219812874\n:60F:C240101EUR1000,00\n" +
+				":61:" + tt.line + "\n:86:Zakupy\n:62F:C240131EUR900,00\n"
+			result, err := ParseMT940(strings.NewReader(sta), "f1", Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Transactions) != 1 {
+				t.Fatalf("got %d transactions, issues %+v", len(result.Transactions), result.Report.Issues)
+			}
+			tx := result.Transactions[0]
+			if tx.Amount != tt.amount || tx.Direction != tt.direction || tx.AmountOriginal != tt.original {
+				t.Errorf("got %d %s %q, want %d %s %q", tx.Amount, tx.Direction, tx.AmountOriginal, tt.amount, tt.direction, tt.original)
+			}
+			if tx.Currency != "EUR" {
+				t.Errorf("currency = %q, want EUR", tx.Currency)
+			}
+		})
+	}
+}
+
+func TestParseMT940Statement(t *testing.T) {
+	sta := `{1:F01BPKOPLPWAXXX0000000000}{2:I940BPKOPLPWXN}{4:
+:20:ST240131
+:25:/PL61109010140000071219812874
+:60F:D231231PLN50,00
+:61:2312311231D123,45NTRFNONREF//REF001
+:86:020~00TRANSFER~20Zakupy spozywcze~21styczen~32BIEDRONKA~33SP Z O.O.
+:61:badline
+:62F:C240131PLN5876,55
+-}`
+	result, err := ParseMT940(strings.NewReader(sta), "f1", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Report.ImportedRows != 1 || result.Report.SkippedRows != 1 {
+		t.Fatalf("report = %+v", result.Report)
+	}
+	tx := result.Transactions[0]
+	if tx.Source != "BIEDRONKA SP Z O.O." || tx.Description != "Zakupy spozywcze styczen" {
+		t.Errorf("source %q, description %q", tx.Source, tx.Description)
+	}
+	if tx.TransactionDate == nil || *tx.TransactionDate != "2023-12-31" {
+		t.Errorf("date = %v", tx.TransactionDate)
+	}
+	if tx.ExternalID == nil || *tx.ExternalID != "REF001" || tx.Bank != "PL61109010140000071219812874" {
+		t.Errorf("external ID %v, bank %q", tx.ExternalID, tx.Bank)
+	}
+	if result.OpeningBalance == nil || *result.OpeningBalance != -5000 {
+		t.Errorf("opening balance = %v, want -5000", result.OpeningBalance)
+	}
+	if result.ClosingBalance == nil || *result.ClosingBalance != 587655 {
+		t.Errorf("closing balance = %v, want 587655", result.ClosingBalance)
+	}
+}
@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"kiro-finance-backend/internal/models"
+)
+
+var ofxEntities = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&", "&quot;", `"`, "&apos;", "'")
+
+type ofxToken struct {
+	name    string
+	closing bool
+	value   string
+}
+
+// ParseOFX parses OFX 1.x (SGML) and 2.x (XML) statements. Both are read
+// with the same tag scanner: SGML leaves its leaf elements unclosed, but in
+// both versions a leaf's value is the text up to the next tag.
+func ParseOFX(reader io.Reader, fileID string, opts Options) (*Result, error) {
+	data, encoding, err := readUTF8(reader, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	content := string(data)
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, errors.New("not an OFX file: <OFX> element not found")
+	}
+
+	result := &Result{
+		Layout: models.ImportLayout{
+			Format:   FormatOFX,
+			Encoding: encoding,
+			Columns:  map[string]string{},
+		},
+		Report: models.ImportReport{
+			FileID: fileID,
+			Issues: []models.ImportIssue{},
+		},
+	}
+
+	var stack []string
+	var bank, currency string
+	var current map[string]string // leaf values of the open STMTTRN
+	var currentRecord []string
+	entry := 0
+
+	for _, tok := range tokenizeOFX(content[start:]) {
+		if tok.closing {
+			// Pop up to the matching aggregate; unclosed SGML leaves are skipped
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == tok.name {
+					stack = stack[:i]
+					break
+				}
+			}
+			if tok.name == "STMTTRN" && current != nil {
+				entry++
+				result.Report.TotalRows++
+				if tx := ofxTransaction(current, currentRecord, entry, bank, currency, fileID, &result.Report); tx != nil {
+					result.Transactions = append(result.Transactions, *tx)
+					result.Report.ImportedRows++
+				} else {
+					result.Report.SkippedRows++
+				}
+				current = nil
+			}
+			continue
+		}
+
+		if tok.value == "" {
+			stack = append(stack, tok.name)
+			if tok.name == "STMTTRN" {
+				current = map[string]string{}
+				currentRecord = nil
+			}
+			continue
+		}
+
+		parent := ""
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+
+		switch {
+		case current != nil:
+			name := tok.name
+			if parent == "CURRENCY" && name == "CURSYM" {
+				// TRNAMT is in this currency rather than CURDEF
+				name = "CURRENCY"
+			}
+			if _, seen := current[name]; !seen {
+				current[name] = tok.value
+			}
+			currentRecord = append(currentRecord, tok.name+"="+tok.value)
+		case parent == "FI" && tok.name == "ORG":
+			bank = tok.value
+		case tok.name == "CURDEF":
+			currency = strings.ToUpper(tok.value)
+		}
+	}
+
+	return result, nil
+}
+
+func ofxTransaction(fields map[string]string, record []string, entry int, bank, currency, fileID string, report *models.ImportReport) *models.Transaction {
+	amountOriginal := fields["TRNAMT"]
+	if amountOriginal == "" {
+		report.AddIssue(entry, record, models.SeverityError, "missing TRNAMT")
+		return nil
+	}
+	amount, err := models.ParseMoney(strings.Replace(amountOriginal, ",", ".", 1))
+	if err != nil {
+		report.AddIssue(entry, record, models.SeverityError, fmt.Sprintf("invalid TRNAMT %q", amountOriginal))
+		return nil
+	}
+
+	var transactionDate *string
+	if posted := fields["DTPOSTED"]; posted != "" {
+		if parsed := parseOFXDate(posted); parsed != "" {
+			transactionDate = &parsed
+		} else {
+			report.AddIssue(entry, record, models.SeverityWarning,
+				fmt.Sprintf("unrecognised DTPOSTED %q, imported without date", posted))
+		}
+	}
+
+	var externalID *string
+	if fitID := fields["FITID"]; fitID != "" {
+		externalID = &fitID
+	} else {
+		report.AddIssue(entry, record, models.SeverityWarning, "missing FITID")
+	}
+
+	// OFX amounts are signed from the account's view
+	direction := models.DirectionCredit
+	if amount < 0 {
+		direction = models.DirectionDebit
+	}
+
+	if code := fields["CURRENCY"]; code != "" {
+		currency = strings.ToUpper(code)
+	}
+
+	source := fields["NAME"]
+	description := fields["MEMO"]
+	if source == "" {
+		source = description
+	}
+	if description == "" {
+		description = source
+	}
+
+	return &models.Transaction{
+		ID:              uuid.New().String(),
+		FileID:          fileID,
+		Source:          source,
+		Description:     description,
+		Amount:          amount.Abs(),
+		AmountOriginal:  amountOriginal,
+		Direction:       direction,
+		Currency:        currency,
+		IsPaid:          true,
+		Bank:            bank,
+		TransactionDate: transactionDate,
+		ExternalID:      externalID,
+		CreatedAt:       time.Now().Unix(),
+	}
+}
+
+func tokenizeOFX(s string) []ofxToken {
+	var tokens []ofxToken
+
+	for {
+		open := strings.IndexByte(s, '<')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(s[open:], '>')
+		if end < 0 {
+			break
+		}
+		tag := strings.TrimSpace(s[open+1 : open+end])
+		s = s[open+end+1:]
+
+		// XML declarations, processing instructions and comments
+		if strings.HasPrefix(tag, "?") || strings.HasPrefix(tag, "!") {
+			continue
+		}
+
+		tok := ofxToken{name: strings.ToUpper(tag)}
+		if strings.HasPrefix(tok.name, "/") {
+			tok.closing = true
+			tok.name = tok.name[1:]
+		} else {
+			value := s
+			if next := strings.IndexByte(s, '<'); next >= 0 {
+				value = s[:next]
+			}
+			tok.value = ofxEntities.Replace(strings.TrimSpace(value))
+		}
+
+		tokens = append(tokens, tok)
+	}
+
+	return tokens
+}
+
+// parseOFXDate reads the date part of an OFX datetime such as
+// 20240105120000.000[-5:EST]
+func parseOFXDate(value string) string {
+	if len(value) < 8 {
+		return ""
+	}
+	t, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"kiro-finance-backend/internal/models"
+)
+
+func TestParseOFXAmounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		trn       string // STMTTRN content
+		amount    models.Money
+		direction string
+		currency  string
+	}{
+		{"debit", "<TRNAMT>-49.99<FITID>A1<NAME>Shop", 4999, models.DirectionDebit, "PLN"},
+		{"credit", "<TRNAMT>5000.00<FITID>A2<NAME>Employer", 500000, models.DirectionCredit, "PLN"},
+		{"unsigned credit", "<TRNAMT>+7<FITID>A3<NAME>Refund", 700, models.DirectionCredit, "PLN"},
+		{"comma decimal", "<TRNAMT>-12,5<FITID>A4<NAME>Shop", 1250, models.DirectionDebit, "PLN"},
+		{"foreign currency", "<TRNAMT>-10.00<FITID>A5<NAME>Hotel<CURRENCY><CURRATE>4.3<CURSYM>eur</CURRENCY>", 1000, models.DirectionDebit, "EUR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ofx := "OFXHEADER:100\n<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><CURDEF>PLN\n<BANKTRANLIST>\n" +
+				"<STMTTRN><TRNTYPE>OTHER<DTPOSTED>20240105120000.000[-5:EST]" + tt.trn + "</STMTTRN>\n" +
+				"</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>"
+			result, err := ParseOFX(strings.NewReader(ofx), "f1", Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Transactions) != 1 {
+				t.Fatalf("got %d transactions, issues %+v", len(result.Transactions), result.Report.Issues)
+			}
+			tx := result.Transactions[0]
+			if tx.Amount != tt.amount || tx.Direction != tt.direction || tx.Currency != tt.currency {
+				t.Errorf("got %d %s %s, want %d %s %s", tx.Amount, tx.Direction, tx.Currency, tt.amount, tt.direction, tt.currency)
+			}
+			if tx.TransactionDate == nil || *tx.TransactionDate != "2024-01-05" {
+				t.Errorf("date = %v", tx.TransactionDate)
+			}
+		})
+	}
+}
+
+func TestParseOFXXML(t *testing.T) {
+	ofx := `<?xml version="1.0"?><?OFX OFXHEADER="200" VERSION="220"?>
+<OFX><SIGNONMSGSRSV1><SONRS><FI><ORG>mBank</ORG></FI></SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS><CURDEF>PLN</CURDEF><BANKTRANLIST>
+<STMTTRN><DTPOSTED>20240110</DTPOSTED><TRNAMT>-20.00</TRNAMT><FITID>B1</FITID><NAME>Caf&amp;e</NAME></STMTTRN>
+<STMTTRN><DTPOSTED>20240111</DTPOSTED><TRNAMT>x</TRNAMT><FITID>B2</FITID></STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`
+	result, err := ParseOFX(strings.NewReader(ofx), "f1", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Report.ImportedRows != 1 || result.Report.SkippedRows != 1 {
+		t.Fatalf("report = %+v", result.Report)
+	}
+	tx := result.Transactions[0]
+	if tx.Amount != 2000 || tx.Direction != models.DirectionDebit || tx.Source != "Caf&e" || tx.Bank != "mBank" {
+		t.Errorf("transaction = %+v", tx)
+	}
+	if tx.ExternalID == nil || *tx.ExternalID != "B1" {
+		t.Errorf("external ID = %v", tx.ExternalID)
+	}
+}
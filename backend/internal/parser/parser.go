@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kiro-finance-backend/internal/models"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatOFX     = "ofx"
+	FormatMT940   = "mt940"
+	FormatCAMT053 = "camt.053"
+	FormatXLSX    = "xlsx"
+)
+
+var formatsByExtension = map[string]string{
+	".csv":   FormatCSV,
+	".ofx":   FormatOFX,
+	".qfx":   FormatOFX,
+	".sta":   FormatMT940,
+	".mt940": FormatMT940,
+	".940":   FormatMT940,
+	".xml":   FormatCAMT053,
+	".xlsx":  FormatXLSX,
+}
+
+// Options control how an uploaded file is parsed
+type Options struct {
+	Profile  *models.ImportProfile // nil uses DefaultProfile
+	Encoding string                // empty to detect
+	Sheets   []string              // workbook sheets to import, empty for all
+}
+
+// Result is the outcome of parsing a file
+type Result struct {
+	Transactions []models.Transaction
+	Layout       models.ImportLayout
+	Report       models.ImportReport
+
+	// Statement balances (credit positive), when the format carries them
+	OpeningBalance *models.Money
+	ClosingBalance *models.Money
+}
+
+// SetFileID assigns the parsed transactions and report to a file
+func (r *Result) SetFileID(fileID string) {
+	r.Report.FileID = fileID
+	for i := range r.Transactions {
+		r.Transactions[i].FileID = fileID
+	}
+}
+
+// SupportedExtensions lists the file extensions Parse accepts
+func SupportedExtensions() []string {
+	var exts []string
+	for ext := range formatsByExtension {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+func IsSupported(filename string) bool {
+	_, ok := formatsByExtension[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// Parse picks the parser from the file extension
+func Parse(reader io.Reader, filename, fileID string, opts Options) (*Result, error) {
+	switch formatsByExtension[strings.ToLower(filepath.Ext(filename))] {
+	case FormatCSV:
+		return ParseCSV(reader, fileID, opts)
+	case FormatOFX:
+		return ParseOFX(reader, fileID, opts)
+	case FormatMT940:
+		return ParseMT940(reader, fileID, opts)
+	case FormatCAMT053:
+		return ParseCAMT053(reader, fileID, opts)
+	case FormatXLSX:
+		return ParseXLSX(reader, fileID, opts)
+	}
+	return nil, fmt.Errorf("unsupported file type %q", filepath.Ext(filename))
+}
+
+// ParseAll is Parse for uploads that may hold several statements: a
+// workbook yields one result per sheet, any other file a single result.
+func ParseAll(reader io.Reader, filename, fileID string, opts Options) ([]*Result, error) {
+	if formatsByExtension[strings.ToLower(filepath.Ext(filename))] == FormatXLSX {
+		return ParseXLSXSheets(reader, fileID, opts)
+	}
+	result, err := Parse(reader, filename, fileID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return []*Result{result}, nil
+}
@@ -0,0 +1,381 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"kiro-finance-backend/internal/models"
+)
+
+// Built-in number formats that display dates or times (ECMA-376 18.8.30)
+var builtinDateFormats = map[int]bool{
+	14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true,
+	27: true, 28: true, 29: true, 30: true, 31: true, 32: true, 33: true, 34: true, 35: true, 36: true,
+	45: true, 46: true, 47: true, 50: true, 51: true, 52: true, 53: true, 54: true, 55: true,
+	56: true, 57: true, 58: true,
+}
+
+// Quoted literals, escaped characters and [colour]/[locale] sections of a
+// format code, removed before looking for date tokens
+var formatLiteralRegex = regexp.MustCompile(`"[^"]*"|\\.|\[[^\]]*\]`)
+
+// Locale-only sections such as [$-415], which name no currency
+var formatLocaleRegex = regexp.MustCompile(`\[\$-[0-9A-Fa-f]+\]`)
+
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	Text string   `xml:"t"`
+	Runs []string `xml:"r>t"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) > 0 {
+		return strings.Join(t.Runs, "")
+	}
+	return t.Text
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Style  int      `xml:"s,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// xlsxSheet is one worksheet read into a table
+type xlsxSheet struct {
+	name  string
+	table table
+}
+
+// ParseXLSX parses the first selected sheet of a workbook
+func ParseXLSX(reader io.Reader, fileID string, opts Options) (*Result, error) {
+	results, err := ParseXLSXSheets(reader, fileID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// ParseXLSXSheets parses the sheets named in opts.Sheets, or every sheet
+// with data, through the same column logic as CSV files. Numbers, dates
+// and booleans are taken from the cell types rather than the text.
+func ParseXLSXSheets(reader io.Reader, fileID string, opts Options) ([]*Result, error) {
+	profile := opts.Profile
+	if profile == nil {
+		profile = DefaultProfile()
+	}
+
+	sheets, err := readWorkbook(reader, opts.Sheets)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	for _, sheet := range sheets {
+		result := &Result{
+			Layout: models.ImportLayout{
+				Format:   FormatXLSX,
+				Encoding: EncodingUTF8,
+				Sheet:    sheet.name,
+				Columns:  map[string]string{},
+			},
+			Report: models.ImportReport{
+				FileID: fileID,
+				Issues: []models.ImportIssue{},
+			},
+		}
+		parseTable(&sheet.table, profile, fileID, result)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// readWorkbook reads the selected sheets in the requested order. Without a
+// selection all sheets that contain cells are returned.
+func readWorkbook(reader io.Reader, selected []string) ([]xlsxSheet, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("not an XLSX workbook: " + err.Error())
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodeZipXML(files, "xl/workbook.xml", &workbook, true); err != nil {
+		return nil, err
+	}
+	var rels xlsxRelationships
+	if err := decodeZipXML(files, "xl/_rels/workbook.xml.rels", &rels, true); err != nil {
+		return nil, err
+	}
+	var shared xlsxSharedStrings
+	if err := decodeZipXML(files, "xl/sharedStrings.xml", &shared, false); err != nil {
+		return nil, err
+	}
+	var styles xlsxStyles
+	if err := decodeZipXML(files, "xl/styles.xml", &styles, false); err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string, len(rels.Items))
+	for _, rel := range rels.Items {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+
+	paths := make(map[string]string, len(workbook.Sheets))
+	var names []string
+	for _, s := range workbook.Sheets {
+		paths[s.Name] = targets[s.RID]
+		names = append(names, s.Name)
+	}
+	if len(selected) > 0 {
+		for _, name := range selected {
+			if _, ok := paths[name]; !ok {
+				return nil, fmt.Errorf("sheet %q not found (available: %s)", name, strings.Join(names, ", "))
+			}
+		}
+		names = selected
+	}
+
+	dateStyles, currencyStyles := styleFormats(styles)
+
+	var sheets []xlsxSheet
+	for _, name := range names {
+		var ws xlsxWorksheet
+		if err := decodeZipXML(files, paths[name], &ws, true); err != nil {
+			return nil, err
+		}
+		t := worksheetTable(ws, shared, dateStyles, currencyStyles, workbook.Properties.Date1904)
+		if len(selected) == 0 && len(t.records) == 0 {
+			continue
+		}
+		sheets = append(sheets, xlsxSheet{name: name, table: t})
+	}
+
+	if len(sheets) == 0 {
+		return nil, errors.New("workbook has no sheets with data")
+	}
+	return sheets, nil
+}
+
+func decodeZipXML(files map[string]*zip.File, name string, v interface{}, required bool) error {
+	f, ok := files[name]
+	if !ok {
+		if required {
+			return fmt.Errorf("not an XLSX workbook: %s missing", name)
+		}
+		return nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+// styleFormats returns the cell styles whose number format shows a date,
+// and the currency shown by currency formats
+func styleFormats(styles xlsxStyles) (map[int]bool, map[int]string) {
+	custom := make(map[int]string, len(styles.NumFmts))
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.Code
+	}
+
+	dates := make(map[int]bool)
+	currencies := make(map[int]string)
+	for i, xf := range styles.CellXfs {
+		code, ok := custom[xf.NumFmtID]
+		if !ok {
+			dates[i] = builtinDateFormats[xf.NumFmtID]
+			continue
+		}
+		dates[i] = isDateFormat(code)
+		if !dates[i] {
+			currencies[i] = detectCurrency(formatLocaleRegex.ReplaceAllString(code, ""))
+		}
+	}
+	return dates, currencies
+}
+
+func isDateFormat(code string) bool {
+	code = strings.ToLower(formatLiteralRegex.ReplaceAllString(code, ""))
+	if code == "general" {
+		return false
+	}
+	return strings.ContainsAny(code, "dmy")
+}
+
+// worksheetTable lays the sheet's cells out by row and column. Numbers and
+// dates are rendered as plain text for header detection and the report,
+// with their native values kept alongside.
+func worksheetTable(ws xlsxWorksheet, shared xlsxSharedStrings, dateStyles map[int]bool, currencyStyles map[int]string, date1904 bool) table {
+	var t table
+
+	for i, row := range ws.Rows {
+		number := row.Number
+		if number == 0 {
+			number = i + 1
+		}
+
+		var record []string
+		cells := typedCells{
+			numbers:    map[int]float64{},
+			dates:      map[int]string{},
+			bools:      map[int]bool{},
+			currencies: map[int]string{},
+		}
+		for j, c := range row.Cells {
+			col := j
+			if c.Ref != "" {
+				col = columnIndex(c.Ref)
+			}
+			if col < 0 {
+				continue
+			}
+
+			var text string
+			switch c.Type {
+			case "s":
+				if idx, err := strconv.Atoi(c.Value); err == nil && idx >= 0 && idx < len(shared.Items) {
+					text = shared.Items[idx].String()
+				}
+			case "inlineStr":
+				text = c.Inline.String()
+			case "b":
+				cells.bools[col] = c.Value == "1"
+				text = strings.ToUpper(strconv.FormatBool(c.Value == "1"))
+			case "d":
+				text = c.Value
+				if len(c.Value) >= 10 {
+					if d, err := time.Parse("2006-01-02", c.Value[:10]); err == nil {
+						text = d.Format("2006-01-02")
+						cells.dates[col] = text
+					}
+				}
+			case "str", "e":
+				text = c.Value
+			default:
+				text = c.Value
+				if c.Value == "" {
+					break
+				}
+				n, err := strconv.ParseFloat(c.Value, 64)
+				if err != nil {
+					break
+				}
+				if dateStyles[c.Style] {
+					text = serialToDate(n, date1904).Format("2006-01-02")
+					cells.dates[col] = text
+				} else {
+					cells.numbers[col] = n
+					if currency := currencyStyles[c.Style]; currency != "" {
+						cells.currencies[col] = currency
+					}
+					text = strconv.FormatFloat(n, 'f', -1, 64)
+				}
+			}
+
+			for len(record) <= col {
+				record = append(record, "")
+			}
+			record[col] = text
+		}
+
+		if isEmptyRow(record) {
+			continue
+		}
+		t.records = append(t.records, record)
+		t.lines = append(t.lines, number)
+		t.cells = append(t.cells, cells)
+	}
+
+	return t
+}
+
+// columnIndex converts the letters of a cell reference such as "AB12" to a
+// zero-based column index
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+// serialToDate converts a spreadsheet date serial number. In the 1900
+// system the epoch is 1899-12-30, which absorbs Excel's fictitious
+// 1900-02-29 for every date after it.
+func serialToDate(serial float64, date1904 bool) time.Time {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := math.Floor(serial)
+	return epoch.AddDate(0, 0, int(days))
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+var (
+	ErrCategoryParent = errors.New("parent category not found, or it is the category itself or one of its subcategories")
+	ErrCategoryInUse  = errors.New("category is used by transactions; merge it into another category instead")
+)
+
+const categoryColumns = `id, name, parent_id, color, icon, aliases,
+	(SELECT COUNT(*) FROM transactions t WHERE t.category = categories.name AND t.deleted_at IS NULL) AS transaction_count,
+	created_at, updated_at`
+
+func scanCategory(s rowScanner) (*models.Category, error) {
+	var c models.Category
+	var aliases string
+	if err := s.Scan(&c.ID, &c.Name, &c.ParentID, &c.Color, &c.Icon, &aliases, &c.TransactionCount, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(aliases), &c.Aliases); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func GetAllCategories() ([]models.Category, error) {
+	rows, err := db.DB.Query("SELECT " + categoryColumns + " FROM categories ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *c)
+	}
+	return categories, rows.Err()
+}
+
+func GetCategoryByID(id string) (*models.Category, error) {
+	c, err := scanCategory(db.DB.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+func FindCategoryByName(name string) (*models.Category, error) {
+	c, err := scanCategory(db.DB.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE name = ?", name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+func CreateCategory(req models.CategoryRequest) (*models.Category, error) {
+	if err := checkCategoryParent(db.DB, "", req.ParentID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	id := uuid.New().String()
+	_, err := db.DB.Exec(`
+		INSERT INTO categories (id, name, parent_id, color, icon, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, id, req.Name, req.ParentID, req.Color, req.Icon, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetCategoryByID(id)
+}
+
+// UpdateCategory changes a category; a new name is also applied to every
+// transaction and recurring pattern using the old one, and the old name is
+// kept as an alias so imports map it to the new one. It returns nil when the
+// category does not exist.
+func UpdateCategory(id string, req models.CategoryRequest, actor string) (*models.Category, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldName, aliasesJSON string
+	err = tx.QueryRow("SELECT name, aliases FROM categories WHERE id = ?", id).Scan(&oldName, &aliasesJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var aliases []string
+	if err := json.Unmarshal([]byte(aliasesJSON), &aliases); err != nil {
+		return nil, err
+	}
+
+	if err := checkCategoryParent(tx, id, req.ParentID); err != nil {
+		return nil, err
+	}
+
+	renamed := req.Name != oldName
+	if renamed {
+		aliases = categoryAliases(req.Name, aliases, oldName)
+	}
+	newAliases, err := json.Marshal(aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE categories SET name = ?, parent_id = ?, color = ?, icon = ?, aliases = ?, updated_at = ?
+		WHERE id = ?
+	`, req.Name, req.ParentID, req.Color, req.Icon, string(newAliases), time.Now().Unix(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if renamed {
+		if err := renameCategoryReferences(tx, oldName, req.Name, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if renamed {
+		TriggerRecurringDetection()
+	}
+	return GetCategoryByID(id)
+}
+
+// MergeCategory moves the transactions, recurring patterns and subcategories
+// of one category into another and deletes it, keeping its names as aliases
+// of the other. It returns the category merged into, or nil when either does
+// not exist.
+func MergeCategory(id, intoID, actor string) (*models.Category, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	categories, err := loadCategories(tx)
+	if err != nil {
+		return nil, err
+	}
+	source, target := categories[id], categories[intoID]
+	if source == nil || target == nil {
+		return nil, nil
+	}
+
+	// A subcategory merged into takes its former ancestor's place first, so
+	// moving the children below it cannot create a cycle
+	if isCategoryAncestor(categories, id, intoID) {
+		if _, err := tx.Exec("UPDATE categories SET parent_id = ? WHERE id = ?", source.ParentID, intoID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec("UPDATE categories SET parent_id = ? WHERE parent_id = ? AND id != ?", intoID, id, intoID); err != nil {
+		return nil, err
+	}
+
+	if err := renameCategoryReferences(tx, source.Name, target.Name, actor); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+	aliases, err := json.Marshal(categoryAliases(target.Name, target.Aliases, append(source.Aliases, source.Name)...))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE categories SET aliases = ?, updated_at = ? WHERE id = ?", string(aliases), time.Now().Unix(), intoID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	TriggerRecurringDetection()
+	return GetCategoryByID(intoID)
+}
+
+// DeleteCategory removes a category that no transaction uses; its
+// subcategories become top-level. Transactions in the trash do not count,
+// as in transactionCount; restoring one registers the category again.
+func DeleteCategory(id string) error {
+	var used int
+	err := db.DB.QueryRow(`
+		SELECT COUNT(*) FROM transactions t JOIN categories c ON t.category = c.name
+		WHERE c.id = ? AND t.deleted_at IS NULL
+	`, id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used > 0 {
+		return ErrCategoryInUse
+	}
+
+	_, err = db.DB.Exec("DELETE FROM categories WHERE id = ?", id)
+	return err
+}
+
+// renameCategoryReferences points transactions, rules and recurring
+// patterns using one category name at another; the transactions' history
+// records it as a change by actor's rename
+func renameCategoryReferences(tx *sql.Tx, oldName, newName, actor string) error {
+	if err := renameTransactions(tx, "category", oldName, newName, models.SystemActor("categories", actor)); err != nil {
+		return err
+	}
+	if err := renameRuleActions(tx, "category", oldName, newName); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE recurring_patterns SET category = ?, updated_at = ? WHERE category = ?", newName, time.Now().Unix(), oldName)
+	return err
+}
+
+// categoryAliases adds former names to a category's aliases, leaving out
+// its current name and repeats
+func categoryAliases(name string, aliases []string, former ...string) []string {
+	result := []string{}
+	seen := map[string]bool{name: true}
+	for _, alias := range append(aliases, former...) {
+		if !seen[alias] {
+			seen[alias] = true
+			result = append(result, alias)
+		}
+	}
+	return result
+}
+
+// NormalizeCategories replaces the former names of categories in parsed
+// transactions with the current ones. A name that belongs to an existing
+// category is left as it is.
+func NormalizeCategories(transactions []models.Transaction) error {
+	categories, err := loadCategories(db.DB)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		names[c.Name] = true
+	}
+	renamed := make(map[string]string)
+	for _, c := range categories {
+		for _, alias := range c.Aliases {
+			if !names[alias] {
+				renamed[alias] = c.Name
+			}
+		}
+	}
+
+	for i := range transactions {
+		if name, ok := renamed[transactions[i].Category]; ok {
+			transactions[i].Category = name
+		}
+	}
+	return nil
+}
+
+// registerCategories adds categories for names not seen before, so every
+// category in use can be given a parent, color and icon
+func registerCategories(exec execer, names ...string) error {
+	now := time.Now().Unix()
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, err := exec.Exec(`
+			INSERT OR IGNORE INTO categories (id, name, created_at, updated_at) VALUES (?, ?, ?, ?)
+		`, uuid.New().String(), name, now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCategoryParent rejects a parent that does not exist or would make
+// the category its own ancestor; id is empty for a new category
+func checkCategoryParent(q queryer, id string, parentID *string) error {
+	if parentID == nil {
+		return nil
+	}
+	categories, err := loadCategories(q)
+	if err != nil {
+		return err
+	}
+	if categories[*parentID] == nil || (id != "" && (*parentID == id || isCategoryAncestor(categories, id, *parentID))) {
+		return ErrCategoryParent
+	}
+	return nil
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadCategories returns every category by ID, without transaction counts
+func loadCategories(q queryer) (map[string]*models.Category, error) {
+	rows, err := q.Query("SELECT id, name, parent_id, color, icon, aliases, created_at, updated_at FROM categories")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[string]*models.Category)
+	for rows.Next() {
+		var c models.Category
+		var aliases string
+		if err := rows.Scan(&c.ID, &c.Name, &c.ParentID, &c.Color, &c.Icon, &aliases, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(aliases), &c.Aliases); err != nil {
+			return nil, err
+		}
+		categories[c.ID] = &c
+	}
+	return categories, rows.Err()
+}
+
+// categoryPath lists a category and its ancestors from the top-level one down
+func categoryPath(categories map[string]*models.Category, id string) []*models.Category {
+	var path []*models.Category
+	for c := categories[id]; c != nil && len(path) <= len(categories); {
+		path = append([]*models.Category{c}, path...)
+		if c.ParentID == nil {
+			break
+		}
+		c = categories[*c.ParentID]
+	}
+	return path
+}
+
+// isCategoryAncestor reports whether ancestorID is above id in the hierarchy
+func isCategoryAncestor(categories map[string]*models.Category, ancestorID, id string) bool {
+	for _, c := range categoryPath(categories, id) {
+		if c.ID == id {
+			break
+		}
+		if c.ID == ancestorID {
+			return true
+		}
+	}
+	return false
+}
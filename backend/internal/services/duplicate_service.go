@@ -0,0 +1,213 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/parser"
+)
+
+// fingerprintBatchSize keeps IN lists well below SQLite's variable limit
+const fingerprintBatchSize = 500
+
+// ApplyDuplicatePolicy fingerprints parsed transactions and handles those
+// matching a transaction already stored in another file. Each stored
+// transaction matches at most one parsed row, so repeated identical rows
+// (two coffees on the same day) only count as duplicates as often as they
+// were imported before.
+func ApplyDuplicatePolicy(result *parser.Result, policy string) error {
+	for i := range result.Transactions {
+		result.Transactions[i].Fingerprint = result.Transactions[i].ComputeFingerprint()
+	}
+
+	existing, err := findExistingByFingerprint(result.Transactions, result.Report.FileID)
+	if err != nil {
+		return err
+	}
+
+	kept := result.Transactions[:0]
+	duplicates := 0
+	for _, t := range result.Transactions {
+		matches := existing[t.Fingerprint]
+		if len(matches) == 0 {
+			kept = append(kept, t)
+			continue
+		}
+		original := matches[0]
+		existing[t.Fingerprint] = matches[1:]
+		duplicates++
+
+		switch policy {
+		case models.DuplicatesSkip:
+			continue
+		case models.DuplicatesFlag:
+			t.DuplicateOf = &original
+		}
+		kept = append(kept, t)
+	}
+	result.Transactions = kept
+
+	report := &result.Report
+	report.DuplicateRows = duplicates
+	if duplicates == 0 {
+		return nil
+	}
+
+	switch policy {
+	case models.DuplicatesSkip:
+		report.ImportedRows -= duplicates
+		report.SkippedRows += duplicates
+		report.AddIssue(0, nil, models.SeverityWarning,
+			fmt.Sprintf("%d rows already imported from other files were skipped", duplicates))
+	case models.DuplicatesFlag:
+		report.AddIssue(0, nil, models.SeverityWarning,
+			fmt.Sprintf("%d rows already imported from other files were flagged as duplicates", duplicates))
+	default:
+		report.AddIssue(0, nil, models.SeverityWarning,
+			fmt.Sprintf("%d rows match transactions already imported from other files", duplicates))
+	}
+	return nil
+}
+
+// findExistingByFingerprint returns the IDs of stored transactions outside
+// fileID, oldest first, for each fingerprint among transactions. Flagged
+// duplicates are not candidates; their original is.
+func findExistingByFingerprint(transactions []models.Transaction, fileID string) (map[string][]string, error) {
+	seen := make(map[string]bool)
+	var fingerprints []interface{}
+	for _, t := range transactions {
+		if !seen[t.Fingerprint] {
+			seen[t.Fingerprint] = true
+			fingerprints = append(fingerprints, t.Fingerprint)
+		}
+	}
+
+	existing := make(map[string][]string)
+	for start := 0; start < len(fingerprints); start += fingerprintBatchSize {
+		end := start + fingerprintBatchSize
+		if end > len(fingerprints) {
+			end = len(fingerprints)
+		}
+		batch := fingerprints[start:end]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+
+		rows, err := db.DB.Query(`
+			SELECT id, fingerprint FROM transactions
+			WHERE fingerprint IN (`+placeholders+`) AND file_id != ? AND duplicate_of IS NULL AND deleted_at IS NULL
+			ORDER BY created_at, id
+		`, append(batch, fileID)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id, fingerprint string
+			if err := rows.Scan(&id, &fingerprint); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			existing[fingerprint] = append(existing[fingerprint], id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return existing, nil
+}
+
+// GetDuplicateGroups lists transactions sharing a fingerprint across files,
+// except those already marked as not duplicates
+func GetDuplicateGroups() ([]models.DuplicateGroup, error) {
+	rows, err := db.DB.Query(`
+		SELECT ` + transactionColumns + `, t.fingerprint
+		FROM transactions t
+		WHERE t.not_duplicate = 0 AND t.deleted_at IS NULL AND t.fingerprint IN (
+			SELECT fingerprint FROM transactions
+			WHERE fingerprint != '' AND not_duplicate = 0 AND deleted_at IS NULL
+			GROUP BY fingerprint
+			HAVING COUNT(DISTINCT file_id) > 1
+		)
+		ORDER BY t.transaction_date DESC, t.fingerprint, t.duplicate_of IS NOT NULL, t.created_at, t.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []models.DuplicateGroup
+	index := make(map[string]int)
+	for rows.Next() {
+		var fingerprint string
+		t, err := scanTransaction(rows, &fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		t.Fingerprint = fingerprint
+
+		i, ok := index[fingerprint]
+		if !ok {
+			i = len(groups)
+			index[fingerprint] = i
+			groups = append(groups, models.DuplicateGroup{Fingerprint: fingerprint})
+		}
+		groups[i].Transactions = append(groups[i].Transactions, *t)
+	}
+
+	return groups, rows.Err()
+}
+
+// ResolveDuplicates moves the duplicates chosen for removal to the trash and
+// marks the ones to keep as distinct transactions, which also brings flagged
+// ones back into stats
+func ResolveDuplicates(resolution models.DuplicateResolution, actor string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range resolution.Delete {
+		t, err := getTransaction(tx, id)
+		if err == sql.ErrNoRows || (err == nil && t.DeletedAt != nil) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := trashTransaction(tx, t, actor); err != nil {
+			return err
+		}
+	}
+	for _, id := range resolution.Keep {
+		before, err := getTransaction(tx, id)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE transactions SET duplicate_of = NULL, not_duplicate = 1 WHERE id = ?", id); err != nil {
+			return err
+		}
+		after, err := getTransaction(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := recordHistory(tx, models.HistoryUpdate, []string{"duplicate_of"}, before, after, actor); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if len(resolution.Delete) > 0 {
+		TriggerRecurringDetection()
+	}
+	return nil
+}
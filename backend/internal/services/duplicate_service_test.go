@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/parser"
+)
+
+func testTransaction(id, fileID, source string, amount models.Money, createdAt int64) models.Transaction {
+	date := "2024-01-05"
+	return models.Transaction{
+		ID: id, FileID: fileID, Category: "Food", Source: source, Description: source,
+		Amount: amount, AmountOriginal: amount.String(), Direction: models.DirectionDebit, Currency: "PLN",
+		TransactionDate: &date, CreatedAt: createdAt,
+	}
+}
+
+func TestApplyDuplicatePolicy(t *testing.T) {
+	openTestDB(t)
+	for _, id := range []string{"f1", "f2", "f3"} {
+		addTestFile(t, id)
+	}
+	original := "b1"
+	flagged := testTransaction("b2", "f3", "Bakery", 450, 4)
+	flagged.DuplicateOf = &original
+	err := SaveTransactions([]models.Transaction{
+		testTransaction("c1", "f1", "Cafe", 700, 1),
+		testTransaction("c2", "f1", "Cafe", 700, 2),
+		testTransaction("b1", "f1", "Bakery", 450, 3),
+		flagged,
+		// Rows already stored in the imported file itself do not count
+		testTransaction("n1", "f2", "New shop", 100, 5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy      string
+		kept        int
+		duplicateOf []string // of the kept rows, "" when not flagged
+		skipped     int
+	}{
+		{models.DuplicatesSkip, 3, []string{"", "", ""}, 3},
+		{models.DuplicatesKeep, 6, []string{"", "", "", "", "", ""}, 0},
+		{models.DuplicatesFlag, 6, []string{"c1", "c2", "", "b1", "", ""}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			// Three coffees where two were imported before, and two loaves
+			// where the stored copy of the second is itself flagged
+			result := &parser.Result{
+				Transactions: []models.Transaction{
+					testTransaction("p1", "f2", "Cafe", 700, 10),
+					testTransaction("p2", "f2", "  CAFE ", 700, 10),
+					testTransaction("p3", "f2", "Cafe", 700, 10),
+					testTransaction("p4", "f2", "Bakery", 450, 10),
+					testTransaction("p5", "f2", "Bakery", 450, 10),
+					testTransaction("p6", "f2", "New shop", 100, 10),
+				},
+				Report: models.ImportReport{FileID: "f2", TotalRows: 6, ImportedRows: 6},
+			}
+			if err := ApplyDuplicatePolicy(result, tt.policy); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(result.Transactions) != tt.kept {
+				t.Fatalf("kept %d rows, want %d", len(result.Transactions), tt.kept)
+			}
+			for i, tx := range result.Transactions {
+				got := ""
+				if tx.DuplicateOf != nil {
+					got = *tx.DuplicateOf
+				}
+				if got != tt.duplicateOf[i] {
+					t.Errorf("row %s duplicate of %q, want %q", tx.ID, got, tt.duplicateOf[i])
+				}
+			}
+			report := result.Report
+			if report.DuplicateRows != 3 || report.SkippedRows != tt.skipped || report.ImportedRows != 6-tt.skipped {
+				t.Errorf("report = %+v", report)
+			}
+		})
+	}
+}
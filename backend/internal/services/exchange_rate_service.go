@@ -0,0 +1,176 @@
+package services
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+
+	"kiro-finance-backend/internal/config"
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/parser"
+)
+
+// Rates are quoted in this currency, as in NBP table A
+const rateQuoteCurrency = "PLN"
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// BaseCurrency is the currency stats are reported in
+func BaseCurrency() string {
+	if config.Cfg == nil || config.Cfg.BaseCurrency == "" {
+		return rateQuoteCurrency
+	}
+	return strings.ToUpper(config.Cfg.BaseCurrency)
+}
+
+// rateSQL is the rate of the currency in expr on the date in dateExpr: the
+// latest published on or before it, or the latest overall when the date is
+// NULL. It is NULL when no such rate exists.
+func rateSQL(expr, dateExpr string) string {
+	return `CASE WHEN ` + expr + ` = '` + rateQuoteCurrency + `' THEN 1.0 ELSE (
+		SELECT r.rate FROM exchange_rates r
+		WHERE r.currency = ` + expr + ` AND r.rate_date <= COALESCE(` + dateExpr + `, '9999-12-31')
+		ORDER BY r.rate_date DESC LIMIT 1
+	) END`
+}
+
+// updateBaseAmounts converts the matching transactions to the base currency
+func updateBaseAmounts(exec execer, where string, args ...interface{}) error {
+	base := BaseCurrency()
+	query := `
+		UPDATE transactions SET amount_base = CASE
+			WHEN currency = ? THEN amount
+			ELSE CAST(ROUND(amount * ` + rateSQL("transactions.currency", "transactions.transaction_date") + ` / ` + rateSQL("?", "transactions.transaction_date") + `) AS INTEGER)
+		END`
+	if where != "" {
+		query += " WHERE " + where
+	}
+
+	_, err := exec.Exec(query, append([]interface{}{base, base, base}, args...)...)
+	return err
+}
+
+// ConvertToBase sets the base currency amount of parsed transactions that
+// are not stored yet, converting them as updateBaseAmounts would. It stays
+// nil when a rate is missing.
+func ConvertToBase(transactions []models.Transaction) error {
+	base := BaseCurrency()
+	query := `SELECT CAST(ROUND(? * ` + rateSQL("?", "?") + ` / ` + rateSQL("?", "?") + `) AS INTEGER)`
+	for i := range transactions {
+		t := &transactions[i]
+		if t.Currency == "" || t.Currency == base {
+			amount := t.Amount
+			t.AmountBase = &amount
+			continue
+		}
+		if err := db.DB.QueryRow(query, t.Amount, t.Currency, t.Currency, t.TransactionDate, base, base, t.TransactionDate).Scan(&t.AmountBase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshBaseAmounts assigns the base currency to transactions imported
+// without one and reconverts every amount, e.g. after the base changed
+func RefreshBaseAmounts() error {
+	if _, err := db.DB.Exec("UPDATE transactions SET currency = ? WHERE currency = ''", BaseCurrency()); err != nil {
+		return err
+	}
+	return updateBaseAmounts(db.DB, "")
+}
+
+// SaveExchangeRates stores rates, replacing any for the same currency and
+// day, and reconverts all transactions
+func SaveExchangeRates(rates []models.ExchangeRate) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO exchange_rates (currency, rate_date, rate)
+		VALUES (?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range rates {
+		if _, err := stmt.Exec(r.Currency, r.Date, r.Rate); err != nil {
+			return err
+		}
+	}
+
+	if err := updateBaseAmounts(tx, ""); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// LoadExchangeRatesFile imports an NBP-style rates CSV from disk
+func LoadExchangeRatesFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	rates, err := parser.ParseExchangeRates(f)
+	if err != nil {
+		return 0, err
+	}
+	if err := SaveExchangeRates(rates); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// GetExchangeRates lists stored rates, newest first. Empty arguments do
+// not filter.
+func GetExchangeRates(currency, dateFrom, dateTo string) ([]models.ExchangeRate, error) {
+	var conditions []string
+	var args []interface{}
+
+	if currency != "" {
+		conditions = append(conditions, "currency = ?")
+		args = append(args, strings.ToUpper(currency))
+	}
+	if dateFrom != "" {
+		conditions = append(conditions, "rate_date >= ?")
+		args = append(args, dateFrom)
+	}
+	if dateTo != "" {
+		conditions = append(conditions, "rate_date <= ?")
+		args = append(args, dateTo)
+	}
+
+	query := "SELECT currency, rate_date, rate FROM exchange_rates"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY rate_date DESC, currency"
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		var r models.ExchangeRate
+		if err := rows.Scan(&r.Currency, &r.Date, &r.Rate); err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+
+	return rates, nil
+}
@@ -1,7 +1,10 @@
 package services
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,9 +12,42 @@ import (
 	"kiro-finance-backend/internal/models"
 )
 
+const fileColumns = `id, name, uploaded_at, sheet_name, size, content_hash, profile_id,
+	(SELECT COUNT(*) FROM transactions t WHERE t.file_id = files.id AND t.deleted_at IS NULL) AS row_count,
+	opening_balance, closing_balance, created_at, updated_at`
+
+// Upload describes the uploaded bytes a file record is created from
+type Upload struct {
+	Name        string
+	Size        int64
+	ContentHash string
+	Data        []byte // kept in the upload store
+}
+
+func NewUpload(name string, data []byte) Upload {
+	sum := sha256.Sum256(data)
+	return Upload{
+		Name:        name,
+		Size:        int64(len(data)),
+		ContentHash: hex.EncodeToString(sum[:]),
+		Data:        data,
+	}
+}
+
+func scanFile(s rowScanner) (*models.File, error) {
+	var f models.File
+	if err := s.Scan(
+		&f.ID, &f.Name, &f.UploadedAt, &f.SheetName, &f.Size, &f.ContentHash, &f.ProfileID, &f.RowCount,
+		&f.OpeningBalance, &f.ClosingBalance, &f.CreatedAt, &f.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
 func GetAllFiles() ([]models.File, error) {
 	rows, err := db.DB.Query(`
-		SELECT id, name, uploaded_at, created_at, updated_at 
+		SELECT ` + fileColumns + `
 		FROM files 
 		ORDER BY uploaded_at DESC
 	`)
@@ -22,22 +58,21 @@ func GetAllFiles() ([]models.File, error) {
 
 	var files []models.File
 	for rows.Next() {
-		var f models.File
-		if err := rows.Scan(&f.ID, &f.Name, &f.UploadedAt, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		f, err := scanFile(rows)
+		if err != nil {
 			return nil, err
 		}
-		files = append(files, f)
+		files = append(files, *f)
 	}
 
 	return files, nil
 }
 
 func GetFileByID(id string) (*models.File, error) {
-	var f models.File
-	err := db.DB.QueryRow(`
-		SELECT id, name, uploaded_at, created_at, updated_at 
+	f, err := scanFile(db.DB.QueryRow(`
+		SELECT `+fileColumns+`
 		FROM files WHERE id = ?
-	`, id).Scan(&f.ID, &f.Name, &f.UploadedAt, &f.CreatedAt, &f.UpdatedAt)
+	`, id))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -46,23 +81,54 @@ func GetFileByID(id string) (*models.File, error) {
 		return nil, err
 	}
 
-	return &f, nil
+	return f, nil
+}
+
+// FindFilesByHash returns the files uploaded with exactly these bytes; a
+// workbook has one per imported sheet
+func FindFilesByHash(contentHash string) ([]models.File, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+fileColumns+`
+		FROM files WHERE content_hash = ?
+		ORDER BY uploaded_at
+	`, contentHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []models.File
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *f)
+	}
+
+	return files, rows.Err()
 }
 
-func CreateFile(name string) (*models.File, error) {
+// CreateFile adds a file record; sheetName is set for workbook sheets and
+// profileID when an import profile was applied
+func CreateFile(upload Upload, sheetName, profileID *string) (*models.File, error) {
 	now := time.Now().Unix()
 	file := &models.File{
-		ID:         uuid.New().String(),
-		Name:       name,
-		UploadedAt: now,
-		CreatedAt:  now,
-		UpdatedAt:  now,
+		ID:          uuid.New().String(),
+		Name:        upload.Name,
+		UploadedAt:  now,
+		SheetName:   sheetName,
+		Size:        upload.Size,
+		ContentHash: upload.ContentHash,
+		ProfileID:   profileID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
 	_, err := db.DB.Exec(`
-		INSERT INTO files (id, name, uploaded_at, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
-	`, file.ID, file.Name, file.UploadedAt, file.CreatedAt, file.UpdatedAt)
+		INSERT INTO files (id, name, uploaded_at, sheet_name, size, content_hash, profile_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, file.ID, file.Name, file.UploadedAt, file.SheetName, file.Size, file.ContentHash, file.ProfileID, file.CreatedAt, file.UpdatedAt)
 
 	if err != nil {
 		return nil, err
@@ -71,11 +137,48 @@ func CreateFile(name string) (*models.File, error) {
 	return file, nil
 }
 
+// ensureManualFile creates the file holding manually entered transactions
+func ensureManualFile(tx *sql.Tx) error {
+	now := time.Now().Unix()
+	_, err := tx.Exec(`
+		INSERT OR IGNORE INTO files (id, name, uploaded_at, created_at, updated_at)
+		VALUES (?, 'Manual entries', ?, ?, ?)
+	`, models.ManualFileID, now, now, now)
+	return err
+}
+
+// UpdateFileBalances stores the statement balances read from the file
+func UpdateFileBalances(file *models.File, opening, closing *models.Money) error {
+	now := time.Now().Unix()
+	_, err := db.DB.Exec(`
+		UPDATE files SET opening_balance = ?, closing_balance = ?, updated_at = ? WHERE id = ?
+	`, opening, closing, now, file.ID)
+	if err != nil {
+		return err
+	}
+
+	file.OpeningBalance = opening
+	file.ClosingBalance = closing
+	file.UpdatedAt = now
+	return nil
+}
+
 func DeleteFile(id string) error {
-	_, err := db.DB.Exec("DELETE FROM files WHERE id = ?", id)
+	var contentHash string
+	err := db.DB.QueryRow("SELECT content_hash FROM files WHERE id = ?", id).Scan(&contentHash)
+	if err == sql.ErrNoRows {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
+
+	if _, err := db.DB.Exec("DELETE FROM files WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := releaseUploadContent(contentHash); err != nil {
+		log.Printf("Failed to remove stored upload %s: %v", contentHash, err)
+	}
 	// Recalculate recurring patterns after file deletion (async with mutex)
 	TriggerRecurringDetection()
 	return nil
@@ -88,40 +191,84 @@ func SaveTransactions(transactions []models.Transaction) error {
 	}
 	defer tx.Rollback()
 
+	for _, t := range transactions {
+		if t.FileID == models.ManualFileID {
+			if err := ensureManualFile(tx); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	if err := insertTransactions(tx, transactions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Recalculate recurring patterns after new transactions (async with mutex)
+	TriggerRecurringDetection()
+	return nil
+}
+
+// insertTransactions stores transactions and converts their amounts to the
+// base currency
+func insertTransactions(tx *sql.Tx, transactions []models.Transaction) error {
 	stmt, err := tx.Prepare(`
-		INSERT INTO transactions (id, file_id, category, source, description, amount, amount_original, is_paid, bank, transaction_date, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO transactions (id, file_id, category, source, description, amount, amount_original, direction, currency, is_paid, bank, transaction_date, external_id, fingerprint, duplicate_of, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
+	fileIDs := make(map[string]bool)
+	var categories []string
 	for _, t := range transactions {
 		isPaid := 0
 		if t.IsPaid {
 			isPaid = 1
 		}
 
+		// Amounts without a currency are taken to be in the base currency
+		currency := t.Currency
+		if currency == "" {
+			currency = BaseCurrency()
+		}
+
+		fingerprint := t.Fingerprint
+		if fingerprint == "" {
+			fingerprint = t.ComputeFingerprint()
+		}
+
 		_, err := stmt.Exec(
 			t.ID, t.FileID, t.Category, t.Source, t.Description,
-			t.Amount, t.AmountOriginal, isPaid, t.Bank, t.TransactionDate, t.CreatedAt,
+			t.Amount, t.AmountOriginal, t.Direction, currency, isPaid, t.Bank, t.TransactionDate, t.ExternalID,
+			fingerprint, t.DuplicateOf, t.CreatedAt,
 		)
 		if err != nil {
 			return err
 		}
+		if len(t.Tags) > 0 {
+			if err := attachTags(tx, t.ID, t.Tags); err != nil {
+				return err
+			}
+		}
+		fileIDs[t.FileID] = true
+		categories = append(categories, t.Category)
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := registerCategories(tx, categories...); err != nil {
 		return err
 	}
 
-	// Recalculate recurring patterns after new transactions (async with mutex)
-	TriggerRecurringDetection()
+	for fileID := range fileIDs {
+		if err := updateBaseAmounts(tx, "file_id = ?", fileID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
-
-func DeleteTransactionsByFileID(fileID string) error {
-	_, err := db.DB.Exec("DELETE FROM transactions WHERE file_id = ?", fileID)
-	return err
-}
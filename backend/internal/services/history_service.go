@@ -0,0 +1,348 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+// ErrNothingToUndo is returned when a transaction has no change left to undo
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// recordHistory stores a change of a transaction with its state before and
+// after it
+func recordHistory(q queryExecer, action string, fields []string, before, after *models.Transaction, actor string) error {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(`
+		INSERT INTO transaction_history (id, transaction_id, action, fields, before_state, after_state, actor, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), before.ID, action, strings.Join(fields, ","), string(beforeJSON), string(afterJSON), actor, time.Now().Unix())
+	return err
+}
+
+// trashTransaction soft-deletes a transaction. The oldest copy flagged as a
+// duplicate of it becomes the original in its place, as hard deletion would
+// leave it, and the other copies are flagged against that one.
+func trashTransaction(q queryExecer, t *models.Transaction, actor string) error {
+	if _, err := q.Exec("UPDATE transactions SET deleted_at = ? WHERE id = ?", time.Now().Unix(), t.ID); err != nil {
+		return err
+	}
+	after, err := getTransaction(q, t.ID)
+	if err != nil {
+		return err
+	}
+	if err := recordHistory(q, models.HistoryDelete, nil, t, after, actor); err != nil {
+		return err
+	}
+
+	var copyID string
+	err = q.QueryRow(`
+		SELECT id FROM transactions
+		WHERE duplicate_of = ? AND deleted_at IS NULL
+		ORDER BY created_at, id
+		LIMIT 1
+	`, t.ID).Scan(&copyID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	before, err := getTransaction(q, copyID)
+	if err != nil {
+		return err
+	}
+	if _, err := q.Exec("UPDATE transactions SET duplicate_of = NULL WHERE id = ?", copyID); err != nil {
+		return err
+	}
+	if _, err := q.Exec("UPDATE transactions SET duplicate_of = ? WHERE duplicate_of = ? AND deleted_at IS NULL", copyID, t.ID); err != nil {
+		return err
+	}
+	promoted, err := getTransaction(q, copyID)
+	if err != nil {
+		return err
+	}
+	return recordHistory(q, models.HistoryPromote, []string{"duplicate_of"}, before, promoted, actor)
+}
+
+// demoteCopies reverses the promotions made when a transaction was trashed:
+// the promoted copies and those flagged against them are flagged as copies
+// of it again, unless a promoted one has since been kept as distinct
+func demoteCopies(tx *sql.Tx, id string) error {
+	rows, err := tx.Query(`
+		SELECT id, transaction_id FROM transaction_history
+		WHERE action = ? AND undone_at IS NULL AND json_extract(before_state, '$.duplicateOf') = ?
+	`, models.HistoryPromote, id)
+	if err != nil {
+		return err
+	}
+	promotions := make(map[string]string)
+	for rows.Next() {
+		var historyID, copyID string
+		if err := rows.Scan(&historyID, &copyID); err != nil {
+			rows.Close()
+			return err
+		}
+		promotions[historyID] = copyID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for historyID, copyID := range promotions {
+		_, err := tx.Exec(`
+			UPDATE transactions SET duplicate_of = ?
+			WHERE (id = ? AND duplicate_of IS NULL AND not_duplicate = 0) OR duplicate_of = ?
+		`, id, copyID, copyID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE transaction_history SET undone_at = ? WHERE id = ?", now, historyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanHistory(s rowScanner) (*models.TransactionHistory, error) {
+	var h models.TransactionHistory
+	var fields string
+	var before, after sql.NullString
+	if err := s.Scan(&h.ID, &h.TransactionID, &h.Action, &fields, &before, &after, &h.Actor, &h.CreatedAt, &h.UndoneAt); err != nil {
+		return nil, err
+	}
+
+	h.Fields = []string{}
+	if fields != "" {
+		h.Fields = strings.Split(fields, ",")
+	}
+	for _, state := range []struct {
+		value sql.NullString
+		dest  **models.Transaction
+	}{{before, &h.Before}, {after, &h.After}} {
+		if !state.value.Valid {
+			continue
+		}
+		var t models.Transaction
+		if err := json.Unmarshal([]byte(state.value.String), &t); err != nil {
+			return nil, err
+		}
+		*state.dest = &t
+	}
+	return &h, nil
+}
+
+const historyColumns = `id, transaction_id, action, fields, before_state, after_state, actor, created_at, undone_at`
+
+// GetTransactionHistory lists the changes of a transaction, newest first
+func GetTransactionHistory(id string) ([]models.TransactionHistory, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+historyColumns+`
+		FROM transaction_history
+		WHERE transaction_id = ?
+		ORDER BY created_at DESC, rowid DESC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.TransactionHistory{}
+	for rows.Next() {
+		h, err := scanHistory(rows)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, *h)
+	}
+	return history, rows.Err()
+}
+
+// UndoTransactionChange reverts the latest update or deletion of a
+// transaction that has not been undone yet, so repeated calls step back
+// through its history. It returns the transaction as restored, or nil when
+// it does not exist.
+func UndoTransactionChange(id, actor string) (*models.Transaction, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := getTransaction(tx, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	change, err := scanHistory(tx.QueryRow(`
+		SELECT `+historyColumns+`
+		FROM transaction_history
+		WHERE transaction_id = ? AND action IN (?, ?) AND undone_at IS NULL
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT 1
+	`, id, models.HistoryUpdate, models.HistoryDelete))
+	if err == sql.ErrNoRows {
+		return nil, ErrNothingToUndo
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var restored *models.Transaction
+	switch change.Action {
+	case models.HistoryDelete:
+		if _, err := tx.Exec("UPDATE transactions SET deleted_at = NULL WHERE id = ?", id); err != nil {
+			return nil, err
+		}
+		if err := demoteCopies(tx, id); err != nil {
+			return nil, err
+		}
+		if restored, err = getTransaction(tx, id); err != nil {
+			return nil, err
+		}
+		// Its category may have been deleted while it was in the trash
+		if err := registerCategories(tx, restored.Category); err != nil {
+			return nil, err
+		}
+		if err := recordHistory(tx, models.HistoryUndo, nil, current, restored, actor); err != nil {
+			return nil, err
+		}
+	case models.HistoryUpdate:
+		// Tag changes are recorded on their own
+		if len(change.Fields) == 1 && change.Fields[0] == "tags" {
+			if err := replaceTags(tx, id, change.Before.Tags); err != nil {
+				return nil, err
+			}
+			if restored, err = recordTagChange(tx, current, models.HistoryUndo, actor); err != nil {
+				return nil, err
+			}
+			break
+		}
+		columns, values := snapshotColumns(change.Before, change.Fields)
+		if restored, err = patchTransaction(tx, current, columns, values, models.HistoryUndo, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE transaction_history SET undone_at = ? WHERE id = ?", time.Now().Unix(), change.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	TriggerRecurringDetection()
+	return restored, nil
+}
+
+// snapshotColumns reads the values of the given columns from a recorded
+// state, as patchColumns would set them
+func snapshotColumns(t *models.Transaction, fields []string) ([]string, []interface{}) {
+	var columns []string
+	var values []interface{}
+	for _, field := range fields {
+		var value interface{}
+		switch field {
+		case "category":
+			value = t.Category
+		case "source":
+			value = t.Source
+		case "description":
+			value = t.Description
+		case "amount":
+			value = t.Amount
+			columns = append(columns, "amount_original")
+			values = append(values, t.AmountOriginal)
+		case "direction":
+			value = t.Direction
+		case "currency":
+			value = t.Currency
+		case "is_paid":
+			isPaid := 0
+			if t.IsPaid {
+				isPaid = 1
+			}
+			value = isPaid
+		case "bank":
+			value = t.Bank
+		case "transaction_date":
+			value = t.TransactionDate
+		case "duplicate_of":
+			value = t.DuplicateOf
+			if t.DuplicateOf != nil {
+				// A flagged copy is not one kept as distinct
+				columns = append(columns, "not_duplicate")
+				values = append(values, 0)
+			}
+		default:
+			continue
+		}
+		columns = append(columns, field)
+		values = append(values, value)
+	}
+	return columns, values
+}
+
+// GetTrash lists deleted transactions, most recently deleted first
+func GetTrash() ([]models.Transaction, error) {
+	rows, err := db.DB.Query(`
+		SELECT ` + transactionColumns + `
+		FROM transactions t
+		WHERE t.deleted_at IS NOT NULL
+		ORDER BY t.deleted_at DESC, t.created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.Transaction{}
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *t)
+	}
+	return transactions, rows.Err()
+}
+
+// PurgeTrash permanently deletes the given transactions from the trash, or
+// all of them when ids is empty, along with their history
+func PurgeTrash(ids []string) (int, error) {
+	query := "DELETE FROM transactions WHERE deleted_at IS NOT NULL"
+	var args []interface{}
+	if len(ids) > 0 {
+		query += " AND id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	result, err := db.DB.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
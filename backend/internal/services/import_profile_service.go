@@ -0,0 +1,150 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+const importProfileColumns = `id, name, header_row, delimiter, columns, paid_marker, date_formats, amount_sign, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanImportProfile(s rowScanner) (*models.ImportProfile, error) {
+	var p models.ImportProfile
+	var columns, dateFormats string
+
+	if err := s.Scan(&p.ID, &p.Name, &p.HeaderRow, &p.Delimiter, &columns, &p.PaidMarker, &dateFormats, &p.AmountSign, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(columns), &p.Columns); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(dateFormats), &p.DateFormats); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func GetAllImportProfiles() ([]models.ImportProfile, error) {
+	rows, err := db.DB.Query("SELECT " + importProfileColumns + " FROM import_profiles ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []models.ImportProfile
+	for rows.Next() {
+		p, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *p)
+	}
+
+	return profiles, nil
+}
+
+func GetImportProfileByID(id string) (*models.ImportProfile, error) {
+	p, err := scanImportProfile(db.DB.QueryRow("SELECT "+importProfileColumns+" FROM import_profiles WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// FindImportProfile looks a profile up by ID first, then by name
+func FindImportProfile(ref string) (*models.ImportProfile, error) {
+	p, err := GetImportProfileByID(ref)
+	if err != nil || p != nil {
+		return p, err
+	}
+
+	p, err = scanImportProfile(db.DB.QueryRow("SELECT "+importProfileColumns+" FROM import_profiles WHERE name = ?", ref))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+func CreateImportProfile(req models.ImportProfileRequest) (*models.ImportProfile, error) {
+	now := time.Now().Unix()
+	p := &models.ImportProfile{
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		HeaderRow:   req.HeaderRow,
+		Delimiter:   req.Delimiter,
+		Columns:     req.Columns,
+		PaidMarker:  req.PaidMarker,
+		DateFormats: req.DateFormats,
+		AmountSign:  req.AmountSign,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if p.DateFormats == nil {
+		p.DateFormats = []string{}
+	}
+
+	columns, err := json.Marshal(p.Columns)
+	if err != nil {
+		return nil, err
+	}
+	dateFormats, err := json.Marshal(p.DateFormats)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.DB.Exec(`
+		INSERT INTO import_profiles (id, name, header_row, delimiter, columns, paid_marker, date_formats, amount_sign, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.ID, p.Name, p.HeaderRow, p.Delimiter, string(columns), p.PaidMarker, string(dateFormats), p.AmountSign, p.CreatedAt, p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func UpdateImportProfile(id string, req models.ImportProfileRequest) (*models.ImportProfile, error) {
+	dateFormats := req.DateFormats
+	if dateFormats == nil {
+		dateFormats = []string{}
+	}
+
+	columnsJSON, err := json.Marshal(req.Columns)
+	if err != nil {
+		return nil, err
+	}
+	dateFormatsJSON, err := json.Marshal(dateFormats)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.DB.Exec(`
+		UPDATE import_profiles
+		SET name = ?, header_row = ?, delimiter = ?, columns = ?, paid_marker = ?, date_formats = ?, amount_sign = ?, updated_at = ?
+		WHERE id = ?
+	`, req.Name, req.HeaderRow, req.Delimiter, string(columnsJSON), req.PaidMarker, string(dateFormatsJSON), req.AmountSign, time.Now().Unix(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, nil
+	}
+
+	return GetImportProfileByID(id)
+}
+
+func DeleteImportProfile(id string) error {
+	_, err := db.DB.Exec("DELETE FROM import_profiles WHERE id = ?", id)
+	return err
+}
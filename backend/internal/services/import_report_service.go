@@ -0,0 +1,58 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+// SaveImportReport stores the report for its file, replacing any earlier one
+func SaveImportReport(report *models.ImportReport) error {
+	return saveImportReport(db.DB, report)
+}
+
+func saveImportReport(exec execer, report *models.ImportReport) error {
+	if report.CreatedAt == 0 {
+		report.CreatedAt = time.Now().Unix()
+	}
+	if report.Issues == nil {
+		report.Issues = []models.ImportIssue{}
+	}
+
+	issues, err := json.Marshal(report.Issues)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`
+		INSERT OR REPLACE INTO import_reports (file_id, total_rows, imported_rows, skipped_rows, duplicate_rows, issues, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, report.FileID, report.TotalRows, report.ImportedRows, report.SkippedRows, report.DuplicateRows, string(issues), report.CreatedAt)
+	return err
+}
+
+func GetImportReport(fileID string) (*models.ImportReport, error) {
+	var r models.ImportReport
+	var issues string
+
+	err := db.DB.QueryRow(`
+		SELECT file_id, total_rows, imported_rows, skipped_rows, duplicate_rows, issues, created_at
+		FROM import_reports WHERE file_id = ?
+	`, fileID).Scan(&r.FileID, &r.TotalRows, &r.ImportedRows, &r.SkippedRows, &r.DuplicateRows, &issues, &r.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(issues), &r.Issues); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
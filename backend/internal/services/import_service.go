@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/parser"
+)
+
+// ImportParsed stores a parsed upload as a new file record with its
+// transactions, import report and statement balances. Workbook sheets are
+// recorded with their sheet name, and rows already imported from other files
+// are handled by the duplicate policy after sources are mapped to their
+// canonical names and the rules have run. Nothing is kept if any step fails.
+func ImportParsed(upload Upload, result *parser.Result, duplicates string) (*models.File, error) {
+	var sheetName *string
+	if result.Layout.Sheet != "" {
+		sheet := result.Layout.Sheet
+		sheetName = &sheet
+	}
+
+	if err := StoreUploadContent(upload); err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	file, err := CreateFile(upload, sheetName, layoutProfileID(result.Layout))
+	if err != nil {
+		releaseUploadContent(upload.ContentHash)
+		return nil, err
+	}
+	result.SetFileID(file.ID)
+
+	if err := NormalizeSources(result.Transactions); err != nil {
+		DeleteFile(file.ID)
+		return nil, fmt.Errorf("failed to apply source aliases: %w", err)
+	}
+	if err := NormalizeCategories(result.Transactions); err != nil {
+		DeleteFile(file.ID)
+		return nil, fmt.Errorf("failed to apply category aliases: %w", err)
+	}
+	if err := ApplyRules(result.Transactions); err != nil {
+		DeleteFile(file.ID)
+		return nil, fmt.Errorf("failed to apply rules: %w", err)
+	}
+	if err := AutoCategorize(result.Transactions); err != nil {
+		DeleteFile(file.ID)
+		return nil, fmt.Errorf("failed to suggest categories: %w", err)
+	}
+	if err := ApplyDuplicatePolicy(result, duplicates); err != nil {
+		DeleteFile(file.ID)
+		return nil, fmt.Errorf("failed to check for duplicates: %w", err)
+	}
+
+	if err := SaveTransactions(result.Transactions); err != nil {
+		DeleteFile(file.ID)
+		return nil, fmt.Errorf("failed to save transactions: %w", err)
+	}
+	file.RowCount = len(result.Transactions)
+
+	if err := SaveImportReport(&result.Report); err != nil {
+		DeleteFile(file.ID)
+		return nil, fmt.Errorf("failed to save import report: %w", err)
+	}
+
+	if result.OpeningBalance != nil || result.ClosingBalance != nil {
+		if err := UpdateFileBalances(file, result.OpeningBalance, result.ClosingBalance); err != nil {
+			DeleteFile(file.ID)
+			return nil, fmt.Errorf("failed to save statement balances: %w", err)
+		}
+	}
+
+	return file, nil
+}
@@ -67,7 +67,7 @@ func GetRecurringPatterns(minConfidence float64, confirmedOnly, includeRejected
 	defer rows.Close()
 
 	var patterns []models.RecurringPattern
-	var totalMonthly, totalYearly float64
+	var totalMonthly, totalYearly models.Money
 
 	for rows.Next() {
 		var p models.RecurringPattern
@@ -93,21 +93,22 @@ func GetRecurringPatterns(minConfidence float64, confirmedOnly, includeRejected
 
 		// Calculate monthly/yearly totals
 		if p.Frequency != nil {
+			avg := p.AvgAmount.Float64()
 			switch *p.Frequency {
 			case "weekly":
-				totalMonthly += p.AvgAmount * 4.33
+				totalMonthly += models.MoneyFromFloat(avg * 4.33)
 				totalYearly += p.AvgAmount * 52
 			case "biweekly":
-				totalMonthly += p.AvgAmount * 2.17
+				totalMonthly += models.MoneyFromFloat(avg * 2.17)
 				totalYearly += p.AvgAmount * 26
 			case "monthly":
 				totalMonthly += p.AvgAmount
 				totalYearly += p.AvgAmount * 12
 			case "quarterly":
-				totalMonthly += p.AvgAmount / 3
+				totalMonthly += models.MoneyFromFloat(avg / 3)
 				totalYearly += p.AvgAmount * 4
 			case "yearly":
-				totalMonthly += p.AvgAmount / 12
+				totalMonthly += models.MoneyFromFloat(avg / 12)
 				totalYearly += p.AvgAmount
 			}
 		}
@@ -116,8 +117,8 @@ func GetRecurringPatterns(minConfidence float64, confirmedOnly, includeRejected
 	return &models.RecurringResponse{
 		Patterns: patterns,
 		Summary: models.RecurringSummary{
-			TotalMonthly: math.Round(totalMonthly*100) / 100,
-			TotalYearly:  math.Round(totalYearly*100) / 100,
+			TotalMonthly: totalMonthly,
+			TotalYearly:  totalYearly,
 			PatternCount: len(patterns),
 		},
 	}, nil
@@ -152,11 +153,10 @@ func GetRecurringPatternByID(id string) (*models.RecurringPatternWithTransaction
 
 	// Get associated transactions
 	rows, err := db.DB.Query(`
-		SELECT t.id, t.file_id, t.category, t.source, t.description, t.amount,
-		       t.amount_original, t.is_paid, t.bank, t.transaction_date, t.created_at
+		SELECT `+transactionColumns+`
 		FROM transactions t
 		JOIN recurring_transactions rt ON t.id = rt.transaction_id
-		WHERE rt.pattern_id = ?
+		WHERE rt.pattern_id = ? AND t.deleted_at IS NULL
 		ORDER BY t.transaction_date DESC, t.created_at DESC
 	`, id)
 	if err != nil {
@@ -166,15 +166,11 @@ func GetRecurringPatternByID(id string) (*models.RecurringPatternWithTransaction
 
 	var transactions []models.Transaction
 	for rows.Next() {
-		var t models.Transaction
-		var isPaid int
-		err := rows.Scan(&t.ID, &t.FileID, &t.Category, &t.Source, &t.Description,
-			&t.Amount, &t.AmountOriginal, &isPaid, &t.Bank, &t.TransactionDate, &t.CreatedAt)
+		t, err := scanTransaction(rows)
 		if err != nil {
 			return nil, err
 		}
-		t.IsPaid = isPaid == 1
-		transactions = append(transactions, t)
+		transactions = append(transactions, *t)
 	}
 
 	return &models.RecurringPatternWithTransactions{
@@ -225,12 +221,12 @@ func DeleteRecurringPattern(id string) error {
 
 // DetectRecurringPatterns runs the detection algorithm on all transactions
 func DetectRecurringPatterns() error {
-	// Get all transactions
+	// Get all outgoing payments; income is not tracked as recurring
 	rows, err := db.DB.Query(`
-		SELECT id, file_id, category, source, description, amount, amount_original,
-		       is_paid, bank, transaction_date, created_at
-		FROM transactions
-		ORDER BY source, category, transaction_date
+		SELECT ` + transactionColumns + `
+		FROM transactions t
+		WHERE t.direction = 'debit' AND t.duplicate_of IS NULL AND t.deleted_at IS NULL
+		ORDER BY t.source, t.category, t.transaction_date
 	`)
 	if err != nil {
 		return err
@@ -239,15 +235,11 @@ func DetectRecurringPatterns() error {
 
 	var transactions []models.Transaction
 	for rows.Next() {
-		var t models.Transaction
-		var isPaid int
-		err := rows.Scan(&t.ID, &t.FileID, &t.Category, &t.Source, &t.Description,
-			&t.Amount, &t.AmountOriginal, &isPaid, &t.Bank, &t.TransactionDate, &t.CreatedAt)
+		t, err := scanTransaction(rows)
 		if err != nil {
 			return err
 		}
-		t.IsPaid = isPaid == 1
-		transactions = append(transactions, t)
+		transactions = append(transactions, *t)
 	}
 
 	// Group transactions
@@ -294,12 +286,12 @@ func detectPatterns(groups []models.TransactionGroup) []models.RecurringPattern
 		// Calculate amount statistics
 		amounts := make([]float64, len(g.Transactions))
 		for i, t := range g.Transactions {
-			amounts[i] = t.Amount
+			amounts[i] = t.Amount.Float64()
 		}
 
 		avgAmount := average(amounts)
-		minAmount := min(amounts)
-		maxAmount := max(amounts)
+		minAmount := models.MoneyFromFloat(min(amounts))
+		maxAmount := models.MoneyFromFloat(max(amounts))
 		amountVariance := stdDev(amounts)
 
 		// Check if amounts are similar enough (within 20% of average)
@@ -385,7 +377,7 @@ func detectPatterns(groups []models.TransactionGroup) []models.RecurringPattern
 			Source:             g.Source,
 			Category:           g.Category,
 			DescriptionPattern: descPattern,
-			AvgAmount:          math.Round(avgAmount*100) / 100,
+			AvgAmount:          models.MoneyFromFloat(avgAmount),
 			MinAmount:          &minAmount,
 			MaxAmount:          &maxAmount,
 			AmountVariance:     &amountVariance,
@@ -468,7 +460,7 @@ func savePatterns(patterns []models.RecurringPattern) error {
 		}
 
 		// Link transactions to pattern
-		txRows, _ := tx.Query(`SELECT id FROM transactions WHERE source = ? AND category = ?`, p.Source, p.Category)
+		txRows, _ := tx.Query(`SELECT id FROM transactions WHERE source = ? AND category = ? AND duplicate_of IS NULL AND deleted_at IS NULL`, p.Source, p.Category)
 		if txRows != nil {
 			var txIDs []string
 			for txRows.Next() {
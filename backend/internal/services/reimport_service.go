@@ -0,0 +1,314 @@
+package services
+
+import (
+	"time"
+
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/parser"
+)
+
+// reimportFields are the columns a reimport refreshes from the file, named
+// as in edited_fields and as reported in the diff
+var reimportFields = []struct {
+	column string
+	name   string
+	equal  func(a, b *models.Transaction) bool
+	copy   func(dst, src *models.Transaction)
+}{
+	{"category", "category",
+		func(a, b *models.Transaction) bool { return a.Category == b.Category },
+		func(dst, src *models.Transaction) { dst.Category = src.Category }},
+	{"source", "source",
+		func(a, b *models.Transaction) bool { return a.Source == b.Source },
+		func(dst, src *models.Transaction) { dst.Source = src.Source }},
+	{"description", "description",
+		func(a, b *models.Transaction) bool { return a.Description == b.Description },
+		func(dst, src *models.Transaction) { dst.Description = src.Description }},
+	{"amount", "amount",
+		func(a, b *models.Transaction) bool { return a.Amount == b.Amount },
+		func(dst, src *models.Transaction) { dst.Amount, dst.AmountOriginal = src.Amount, src.AmountOriginal }},
+	{"direction", "direction",
+		func(a, b *models.Transaction) bool { return a.Direction == b.Direction },
+		func(dst, src *models.Transaction) { dst.Direction = src.Direction }},
+	{"currency", "currency",
+		func(a, b *models.Transaction) bool { return a.Currency == b.Currency },
+		func(dst, src *models.Transaction) { dst.Currency = src.Currency }},
+	{"is_paid", "isPaid",
+		func(a, b *models.Transaction) bool { return a.IsPaid == b.IsPaid },
+		func(dst, src *models.Transaction) { dst.IsPaid = src.IsPaid }},
+	{"bank", "bank",
+		func(a, b *models.Transaction) bool { return a.Bank == b.Bank },
+		func(dst, src *models.Transaction) { dst.Bank = src.Bank }},
+	{"transaction_date", "transactionDate",
+		func(a, b *models.Transaction) bool { return stringPtrEqual(a.TransactionDate, b.TransactionDate) },
+		func(dst, src *models.Transaction) { dst.TransactionDate = src.TransactionDate }},
+	{"external_id", "externalId",
+		func(a, b *models.Transaction) bool { return stringPtrEqual(a.ExternalID, b.ExternalID) },
+		func(dst, src *models.Transaction) { dst.ExternalID = src.ExternalID }},
+}
+
+// storedTransaction is a transaction of the file being reimported
+type storedTransaction struct {
+	models.Transaction
+	edited map[string]bool
+}
+
+// ReimportFile replaces a file's transactions with a new upload of it in a
+// single database transaction. Rows matching a stored transaction keep its
+// ID, recurring links and fields edited by hand; the rest are added or
+// removed. With dryRun the changes are computed and rolled back.
+func ReimportFile(file *models.File, upload Upload, result *parser.Result, duplicates string, dryRun bool) (*models.ReimportDiff, error) {
+	for i := range result.Transactions {
+		if result.Transactions[i].Currency == "" {
+			result.Transactions[i].Currency = BaseCurrency()
+		}
+	}
+	if err := NormalizeSources(result.Transactions); err != nil {
+		return nil, err
+	}
+	if err := NormalizeCategories(result.Transactions); err != nil {
+		return nil, err
+	}
+	if err := ApplyRules(result.Transactions); err != nil {
+		return nil, err
+	}
+	if err := AutoCategorize(result.Transactions); err != nil {
+		return nil, err
+	}
+	if err := ApplyDuplicatePolicy(result, duplicates); err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		if err := StoreUploadContent(upload); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Trashed transactions take no part: they neither match parsed rows nor
+	// count as removed, and stay in the trash
+	rows, err := tx.Query(`
+		SELECT `+transactionColumns+`, t.fingerprint, t.edited_fields
+		FROM transactions t WHERE t.file_id = ? AND t.deleted_at IS NULL
+		ORDER BY t.created_at, t.id
+	`, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	var stored []storedTransaction
+	for rows.Next() {
+		var fingerprint, edited string
+		t, err := scanTransaction(rows, &fingerprint, &edited)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.Fingerprint = fingerprint
+		stored = append(stored, storedTransaction{Transaction: *t, edited: parseEditedFields(edited)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	matches := matchReimport(stored, result.Transactions)
+
+	diff := &models.ReimportDiff{
+		DryRun:  dryRun,
+		Added:   []models.Transaction{},
+		Removed: []models.Transaction{},
+		Changed: []models.TransactionChange{},
+	}
+	matched := make([]bool, len(stored))
+	// Tags from rules are added to matched transactions, never removed
+	var tagged []models.Transaction
+
+	for i, parsed := range result.Transactions {
+		s := matches[i]
+		if s < 0 {
+			diff.Added = append(diff.Added, parsed)
+			continue
+		}
+		matched[s] = true
+		if len(parsed.Tags) > 0 {
+			tagged = append(tagged, models.Transaction{ID: stored[s].ID, Tags: parsed.Tags})
+		}
+
+		before := stored[s]
+		after := before.Transaction
+		change := models.TransactionChange{Before: before.Transaction}
+		for _, f := range reimportFields {
+			if f.equal(&before.Transaction, &parsed) {
+				continue
+			}
+			if before.edited[f.column] {
+				change.KeptEdits = append(change.KeptEdits, f.name)
+				continue
+			}
+			f.copy(&after, &parsed)
+			change.Fields = append(change.Fields, f.name)
+		}
+		if len(change.Fields) == 0 {
+			diff.Unchanged++
+			continue
+		}
+
+		after.Fingerprint = after.ComputeFingerprint()
+		change.After = after
+		diff.Changed = append(diff.Changed, change)
+	}
+
+	for i, s := range stored {
+		if !matched[i] {
+			diff.Removed = append(diff.Removed, s.Transaction)
+		}
+	}
+
+	for _, t := range diff.Removed {
+		if _, err := tx.Exec("DELETE FROM transactions WHERE id = ?", t.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range diff.Changed {
+		t := c.After
+		isPaid := 0
+		if t.IsPaid {
+			isPaid = 1
+		}
+		_, err := tx.Exec(`
+			UPDATE transactions SET category = ?, source = ?, description = ?, amount = ?, amount_original = ?,
+				direction = ?, currency = ?, is_paid = ?, bank = ?, transaction_date = ?, external_id = ?, fingerprint = ?
+			WHERE id = ?
+		`, t.Category, t.Source, t.Description, t.Amount, t.AmountOriginal,
+			t.Direction, t.Currency, isPaid, t.Bank, t.TransactionDate, t.ExternalID, t.Fingerprint, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := registerCategories(tx, t.Category); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range tagged {
+		if err := attachTags(tx, t.ID, t.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := insertTransactions(tx, diff.Added); err != nil {
+		return nil, err
+	}
+	if err := updateBaseAmounts(tx, "file_id = ?", file.ID); err != nil {
+		return nil, err
+	}
+
+	if err := saveImportReport(tx, &result.Report); err != nil {
+		return nil, err
+	}
+
+	// Sheet and balances follow the new upload; balances are cleared when it
+	// has none
+	sheetName := file.SheetName
+	if sheet := result.Layout.Sheet; sheet != "" {
+		sheetName = &sheet
+	}
+	profileID := layoutProfileID(result.Layout)
+	now := time.Now().Unix()
+	_, err = tx.Exec(`
+		UPDATE files SET size = ?, content_hash = ?, sheet_name = ?, profile_id = ?, opening_balance = ?, closing_balance = ?, updated_at = ?
+		WHERE id = ?
+	`, upload.Size, upload.ContentHash, sheetName, profileID, result.OpeningBalance, result.ClosingBalance, now, file.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return diff, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if upload.ContentHash != file.ContentHash {
+		releaseUploadContent(file.ContentHash)
+	}
+
+	file.Size = upload.Size
+	file.ContentHash = upload.ContentHash
+	file.SheetName = sheetName
+	file.ProfileID = profileID
+	file.OpeningBalance = result.OpeningBalance
+	file.ClosingBalance = result.ClosingBalance
+	file.RowCount = len(result.Transactions)
+	file.UpdatedAt = now
+
+	if len(diff.Added) > 0 || len(diff.Removed) > 0 || len(diff.Changed) > 0 {
+		TriggerRecurringDetection()
+	}
+	return diff, nil
+}
+
+// matchReimport pairs each parsed row with a stored transaction, returning
+// the stored index per parsed row or -1. Rows are matched by bank ID, then
+// by fingerprint, then by date and amount, so a corrected description still
+// counts as the same transaction.
+func matchReimport(stored []storedTransaction, parsed []models.Transaction) []int {
+	matches := make([]int, len(parsed))
+	for i := range matches {
+		matches[i] = -1
+	}
+	used := make([]bool, len(stored))
+
+	keys := []func(t *models.Transaction) string{
+		func(t *models.Transaction) string {
+			if t.ExternalID == nil {
+				return ""
+			}
+			return *t.ExternalID
+		},
+		func(t *models.Transaction) string { return t.Fingerprint },
+		func(t *models.Transaction) string {
+			if t.TransactionDate == nil {
+				return ""
+			}
+			return *t.TransactionDate + "|" + t.Direction + "|" + t.Amount.String()
+		},
+	}
+
+	for _, key := range keys {
+		candidates := make(map[string][]int)
+		for i := range stored {
+			if k := key(&stored[i].Transaction); !used[i] && k != "" {
+				candidates[k] = append(candidates[k], i)
+			}
+		}
+		for i := range parsed {
+			if matches[i] >= 0 {
+				continue
+			}
+			k := key(&parsed[i])
+			if k == "" || len(candidates[k]) == 0 {
+				continue
+			}
+			matches[i] = candidates[k][0]
+			used[candidates[k][0]] = true
+			candidates[k] = candidates[k][1:]
+		}
+	}
+
+	return matches
+}
+
+func stringPtrEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"testing"
+
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+	"kiro-finance-backend/internal/parser"
+)
+
+func TestMatchReimport(t *testing.T) {
+	row := func(externalID, description string, amount models.Money, direction, date string) models.Transaction {
+		tx := models.Transaction{Source: "Shop", Description: description, Amount: amount, Direction: direction}
+		if externalID != "" {
+			tx.ExternalID = &externalID
+		}
+		if date != "" {
+			tx.TransactionDate = &date
+		}
+		tx.Fingerprint = tx.ComputeFingerprint()
+		return tx
+	}
+	debit, credit := models.DirectionDebit, models.DirectionCredit
+
+	tests := []struct {
+		name   string
+		stored []models.Transaction
+		parsed []models.Transaction
+		want   []int
+	}{
+		{
+			name:   "bank ID despite a corrected amount",
+			stored: []models.Transaction{row("A1", "Bread", 450, debit, "2024-01-05"), row("A2", "Milk", 300, debit, "2024-01-05")},
+			parsed: []models.Transaction{row("A2", "Milk", 350, debit, "2024-01-06"), row("A1", "Bread", 450, debit, "2024-01-05")},
+			want:   []int{1, 0},
+		},
+		{
+			name:   "fingerprint without bank IDs",
+			stored: []models.Transaction{row("", "Bread", 450, debit, "2024-01-05"), row("", "Milk", 450, debit, "2024-01-05")},
+			parsed: []models.Transaction{row("", "Milk", 450, debit, "2024-01-05")},
+			want:   []int{1},
+		},
+		{
+			name:   "date, direction and amount after a corrected description",
+			stored: []models.Transaction{row("", "Bread", 450, debit, "2024-01-05")},
+			parsed: []models.Transaction{row("", "Bread and rolls", 450, debit, "2024-01-05")},
+			want:   []int{0},
+		},
+		{
+			name:   "fingerprint wins over the date fallback",
+			stored: []models.Transaction{row("", "Bread", 450, debit, "2024-01-05"), row("", "Rolls", 450, debit, "2024-01-05")},
+			parsed: []models.Transaction{row("", "Cake", 450, debit, "2024-01-05"), row("", "Rolls", 450, debit, "2024-01-05")},
+			want:   []int{0, 1},
+		},
+		{
+			name:   "two identical rows on the same day",
+			stored: []models.Transaction{row("", "Coffee", 700, debit, "2024-01-05"), row("", "Coffee", 700, debit, "2024-01-05")},
+			parsed: []models.Transaction{row("", "Coffee", 700, debit, "2024-01-05"), row("", "Coffee", 700, debit, "2024-01-05"), row("", "Coffee", 700, debit, "2024-01-05")},
+			want:   []int{0, 1, -1},
+		},
+		{
+			name:   "other direction",
+			stored: []models.Transaction{row("", "Refund", 450, debit, "2024-01-05")},
+			parsed: []models.Transaction{row("", "Refund!", 450, credit, "2024-01-05")},
+			want:   []int{-1},
+		},
+		{
+			name:   "no date to fall back on",
+			stored: []models.Transaction{row("", "Bread", 450, debit, "")},
+			parsed: []models.Transaction{row("", "Rolls", 450, debit, "")},
+			want:   []int{-1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := make([]storedTransaction, len(tt.stored))
+			for i, tx := range tt.stored {
+				stored[i] = storedTransaction{Transaction: tx}
+			}
+			got := matchReimport(stored, tt.parsed)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("matchReimport() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestReimportFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		dryRun bool
+	}{
+		{"dry run", true},
+		{"apply", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			addTestFile(t, "f1")
+			err := SaveTransactions([]models.Transaction{
+				testTransaction("a", "f1", "Cafe", 700, 1),
+				testTransaction("b", "f1", "Bakery", 450, 2),
+				testTransaction("c", "f1", "Gym", 9900, 3),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// As if edited by hand
+			if _, err := db.DB.Exec("UPDATE transactions SET description = 'Bread', edited_fields = 'description' WHERE id = 'b'"); err != nil {
+				t.Fatal(err)
+			}
+
+			// The bakery row has a new description, which the hand edit
+			// keeps, and is now paid; the gym row is gone and a new one added
+			bakery := testTransaction("p2", "f1", "Bakery", 450, 10)
+			bakery.Description = "BAKERY POS 123"
+			bakery.IsPaid = true
+			result := &parser.Result{
+				Transactions: []models.Transaction{
+					testTransaction("p1", "f1", "Cafe", 700, 10),
+					bakery,
+					testTransaction("p3", "f1", "Cinema", 3000, 10),
+				},
+				Report: models.ImportReport{FileID: "f1", TotalRows: 3, ImportedRows: 3, Issues: []models.ImportIssue{}},
+			}
+			file, err := GetFileByID("f1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			diff, err := ReimportFile(file, NewUpload("f1.csv", []byte("new")), result, models.DuplicatesFlag, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff.Unchanged != 1 || len(diff.Added) != 1 || len(diff.Removed) != 1 || len(diff.Changed) != 1 {
+				t.Fatalf("diff = %+v", diff)
+			}
+			change := diff.Changed[0]
+			if change.Before.ID != "b" || len(change.Fields) != 1 || change.Fields[0] != "isPaid" ||
+				len(change.KeptEdits) != 1 || change.KeptEdits[0] != "description" {
+				t.Errorf("change = %+v", change)
+			}
+
+			stored, err := GetTransactions(models.TransactionFilter{FileIDs: []string{"f1"}}, 1, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := map[string]models.Transaction{}
+			for _, tx := range stored.Data {
+				ids[tx.ID] = tx
+			}
+			if tt.dryRun {
+				if len(ids) != 3 || ids["c"].ID == "" || ids["b"].IsPaid {
+					t.Errorf("dry run changed the file: %+v", stored.Data)
+				}
+				return
+			}
+			if len(ids) != 3 || ids["c"].ID != "" || ids["p3"].ID == "" {
+				t.Errorf("transactions = %+v", stored.Data)
+			}
+			if b := ids["b"]; !b.IsPaid || b.Description != "Bread" {
+				t.Errorf("bakery = %+v, want paid with the edited description", b)
+			}
+		})
+	}
+}
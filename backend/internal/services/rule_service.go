@@ -0,0 +1,408 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+// ErrRuleOrder is returned when a new rule order does not list every rule
+// exactly once
+var ErrRuleOrder = errors.New("ids must list every rule exactly once")
+
+const ruleColumns = `id, name, position, enabled, stop, conditions, actions, created_at, updated_at`
+
+func scanRule(s rowScanner) (*models.Rule, error) {
+	var r models.Rule
+	var conditions, actions string
+	if err := s.Scan(&r.ID, &r.Name, &r.Position, &r.Enabled, &r.Stop, &conditions, &actions, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(conditions), &r.Conditions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(actions), &r.Actions); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func GetAllRules() ([]models.Rule, error) {
+	return loadRules(db.DB, false)
+}
+
+// loadRules returns the rules in the order they run
+func loadRules(q queryer, enabledOnly bool) ([]models.Rule, error) {
+	query := "SELECT " + ruleColumns + " FROM rules"
+	if enabledOnly {
+		query += " WHERE enabled = 1"
+	}
+	rows, err := q.Query(query + " ORDER BY position, created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.Rule{}
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *r)
+	}
+	return rules, rows.Err()
+}
+
+func GetRuleByID(id string) (*models.Rule, error) {
+	r, err := scanRule(db.DB.QueryRow("SELECT "+ruleColumns+" FROM rules WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+func marshalRule(req models.RuleRequest) (conditions, actions string, err error) {
+	c, err := json.Marshal(req.Conditions)
+	if err != nil {
+		return "", "", err
+	}
+	a, err := json.Marshal(req.Actions)
+	if err != nil {
+		return "", "", err
+	}
+	return string(c), string(a), nil
+}
+
+// CreateRule adds a rule that runs after the existing ones
+func CreateRule(req models.RuleRequest) (*models.Rule, error) {
+	conditions, actions, err := marshalRule(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	id := uuid.New().String()
+	_, err = db.DB.Exec(`
+		INSERT INTO rules (id, name, position, enabled, stop, conditions, actions, created_at, updated_at)
+		VALUES (?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM rules), ?, ?, ?, ?, ?, ?)
+	`, id, req.Name, req.Enabled == nil || *req.Enabled, req.Stop, conditions, actions, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRuleByID(id)
+}
+
+// UpdateRule replaces a rule, keeping its position. It returns nil when the
+// rule does not exist.
+func UpdateRule(id string, req models.RuleRequest) (*models.Rule, error) {
+	conditions, actions, err := marshalRule(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.DB.Exec(`
+		UPDATE rules SET name = ?, enabled = ?, stop = ?, conditions = ?, actions = ?, updated_at = ?
+		WHERE id = ?
+	`, req.Name, req.Enabled == nil || *req.Enabled, req.Stop, conditions, actions, time.Now().Unix(), id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+
+	return GetRuleByID(id)
+}
+
+func DeleteRule(id string) error {
+	_, err := db.DB.Exec("DELETE FROM rules WHERE id = ?", id)
+	return err
+}
+
+// renameRuleActions points rules setting a category or source (the action
+// key) to one name at another
+func renameRuleActions(exec execer, key, oldName, newName string) error {
+	_, err := exec.Exec(`
+		UPDATE rules SET actions = json_set(actions, '$.' || ?, ?), updated_at = ?
+		WHERE json_extract(actions, '$.' || ?) = ?
+	`, key, newName, time.Now().Unix(), key, oldName)
+	return err
+}
+
+// ReorderRules sets the order rules run in and returns them in it
+func ReorderRules(ids []string) ([]models.Rule, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rules, err := loadRules(tx, false)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		known[r.ID] = true
+	}
+	if len(ids) != len(rules) {
+		return nil, ErrRuleOrder
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return nil, ErrRuleOrder
+		}
+		delete(known, id)
+	}
+
+	now := time.Now().Unix()
+	for i, id := range ids {
+		if _, err := tx.Exec("UPDATE rules SET position = ?, updated_at = ? WHERE id = ?", i+1, now, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return GetAllRules()
+}
+
+// ruleEngine runs the enabled rules with their regular expressions compiled
+type ruleEngine struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	models.Rule
+	regexes []*regexp.Regexp // by condition, nil unless it uses RuleOpRegex
+}
+
+func loadRuleEngine(q queryer) (*ruleEngine, error) {
+	rules, err := loadRules(q, true)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &ruleEngine{}
+	for _, r := range rules {
+		cr := compiledRule{Rule: r, regexes: make([]*regexp.Regexp, len(r.Conditions))}
+		for i, c := range r.Conditions {
+			if c.Operator == models.RuleOpRegex {
+				// Patterns are validated when saved
+				if cr.regexes[i], err = regexp.Compile(c.Value); err != nil {
+					return nil, err
+				}
+			}
+		}
+		e.rules = append(e.rules, cr)
+	}
+	return e, nil
+}
+
+// apply runs the rules over a transaction, changing it in place, and
+// returns the names of the rules that matched. Later rules see the changes
+// of earlier ones.
+func (e *ruleEngine) apply(t *models.Transaction) []string {
+	var matched []string
+	for _, r := range e.rules {
+		if !r.matches(t) {
+			continue
+		}
+		matched = append(matched, r.Name)
+
+		if r.Actions.Category != nil {
+			t.Category = *r.Actions.Category
+		}
+		if r.Actions.Source != nil {
+			t.Source = *r.Actions.Source
+		}
+		if r.Actions.IsPaid != nil {
+			t.IsPaid = *r.Actions.IsPaid
+		}
+		for _, tag := range r.Actions.Tags {
+			if !slices.Contains(t.Tags, tag) {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+		if r.Stop {
+			break
+		}
+	}
+	return matched
+}
+
+func (r *compiledRule) matches(t *models.Transaction) bool {
+	for i, c := range r.Conditions {
+		if !conditionMatches(c, r.regexes[i], t) {
+			return false
+		}
+	}
+	return len(r.Conditions) > 0
+}
+
+func conditionMatches(c models.RuleCondition, re *regexp.Regexp, t *models.Transaction) bool {
+	switch c.Field {
+	case models.RuleFieldAmount:
+		return (c.Min == nil || t.Amount >= *c.Min) && (c.Max == nil || t.Amount <= *c.Max)
+	case models.RuleFieldDate:
+		if t.TransactionDate == nil {
+			return false
+		}
+		date := *t.TransactionDate
+		return (c.From == "" || date >= c.From) && (c.To == "" || date <= c.To)
+	}
+
+	var value string
+	switch c.Field {
+	case models.RuleFieldSource:
+		value = t.Source
+	case models.RuleFieldDescription:
+		value = t.Description
+	case models.RuleFieldBank:
+		value = t.Bank
+	default:
+		return false
+	}
+
+	if re != nil {
+		return re.MatchString(value)
+	}
+	value, pattern := strings.ToLower(value), strings.ToLower(c.Value)
+	switch c.Operator {
+	case models.RuleOpEquals:
+		return value == pattern
+	case models.RuleOpContains:
+		return strings.Contains(value, pattern)
+	case models.RuleOpPrefix:
+		return strings.HasPrefix(value, pattern)
+	}
+	return false
+}
+
+// ApplyRules runs the enabled rules over parsed transactions
+func ApplyRules(transactions []models.Transaction) error {
+	e, err := loadRuleEngine(db.DB)
+	if err != nil {
+		return err
+	}
+	if len(e.rules) == 0 {
+		return nil
+	}
+	for i := range transactions {
+		e.apply(&transactions[i])
+	}
+	return nil
+}
+
+// ApplyRulesToTransactions runs the enabled rules over the stored
+// transactions matching the filter, recording each change in their history.
+// With dryRun the changes are only reported.
+func ApplyRulesToTransactions(filter models.TransactionFilter, dryRun bool, actor string) (*models.RuleApplyResult, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	e, err := loadRuleEngine(tx)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := matchingTransactions(tx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RuleApplyResult{DryRun: dryRun, Checked: len(transactions), Changes: []models.RuleChange{}}
+	for i := range transactions {
+		before := &transactions[i]
+		after := *before
+		after.Tags = append([]string{}, before.Tags...)
+		matched := e.apply(&after)
+		if len(matched) == 0 {
+			continue
+		}
+
+		var fields []models.RuleFieldChange
+		var columns []string
+		var values []interface{}
+		if after.Category != before.Category {
+			fields = append(fields, models.RuleFieldChange{Field: "category", From: before.Category, To: after.Category})
+			columns = append(columns, "category")
+			values = append(values, after.Category)
+		}
+		if after.Source != before.Source {
+			fields = append(fields, models.RuleFieldChange{Field: "source", From: before.Source, To: after.Source})
+			columns = append(columns, "source")
+			values = append(values, after.Source)
+		}
+		if after.IsPaid != before.IsPaid {
+			isPaid := 0
+			if after.IsPaid {
+				isPaid = 1
+			}
+			fields = append(fields, models.RuleFieldChange{Field: "is_paid", From: before.IsPaid, To: after.IsPaid})
+			columns = append(columns, "is_paid")
+			values = append(values, isPaid)
+		}
+		added := after.Tags[len(before.Tags):]
+		if len(added) > 0 {
+			fields = append(fields, models.RuleFieldChange{Field: "tags", From: before.Tags, To: after.Tags})
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		result.Updated++
+		result.Changes = append(result.Changes, models.RuleChange{
+			TransactionID:   before.ID,
+			TransactionDate: before.TransactionDate,
+			Source:          before.Source,
+			Description:     before.Description,
+			Amount:          before.Amount,
+			Rules:           matched,
+			Fields:          fields,
+		})
+
+		if dryRun {
+			continue
+		}
+		current := before
+		if len(columns) > 0 {
+			if current, err = patchTransaction(tx, before, columns, values, models.HistoryUpdate, models.SystemActor("rules", actor)); err != nil {
+				return nil, err
+			}
+		}
+		if len(added) > 0 {
+			if err := attachTags(tx, before.ID, added); err != nil {
+				return nil, err
+			}
+			if _, err := recordTagChange(tx, current, models.HistoryUpdate, models.SystemActor("rules", actor)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if result.Updated > 0 {
+		TriggerRecurringDetection()
+	}
+	return result, nil
+}
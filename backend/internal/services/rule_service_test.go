@@ -0,0 +1,80 @@
+package services
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"kiro-finance-backend/internal/models"
+)
+
+func TestConditionMatches(t *testing.T) {
+	date := "2024-03-15"
+	tx := &models.Transaction{Source: "NETFLIX.COM", Description: "Subscription March", Bank: "mBank", Amount: 4999, TransactionDate: &date}
+	money := func(m models.Money) *models.Money { return &m }
+
+	tests := []struct {
+		name string
+		c    models.RuleCondition
+		want bool
+	}{
+		{"equals ignores case", models.RuleCondition{Field: "source", Operator: "equals", Value: "netflix.com"}, true},
+		{"equals whole value", models.RuleCondition{Field: "source", Operator: "equals", Value: "netflix"}, false},
+		{"contains", models.RuleCondition{Field: "description", Operator: "contains", Value: "MARCH"}, true},
+		{"contains misses", models.RuleCondition{Field: "description", Operator: "contains", Value: "april"}, false},
+		{"prefix", models.RuleCondition{Field: "bank", Operator: "prefix", Value: "mb"}, true},
+		{"prefix is not suffix", models.RuleCondition{Field: "bank", Operator: "prefix", Value: "bank"}, false},
+		{"regex", models.RuleCondition{Field: "source", Operator: "regex", Value: `^NETFLIX\.`}, true},
+		{"regex keeps case", models.RuleCondition{Field: "source", Operator: "regex", Value: `^netflix`}, false},
+		{"amount in range", models.RuleCondition{Field: "amount", Min: money(4000), Max: money(4999)}, true},
+		{"amount below min", models.RuleCondition{Field: "amount", Min: money(5000)}, false},
+		{"amount open range", models.RuleCondition{Field: "amount"}, true},
+		{"date from", models.RuleCondition{Field: "date", From: "2024-03-15"}, true},
+		{"date to", models.RuleCondition{Field: "date", To: "2024-03-14"}, false},
+		{"date range", models.RuleCondition{Field: "date", From: "2024-03-01", To: "2024-03-31"}, true},
+		{"unknown field", models.RuleCondition{Field: "category", Operator: "contains", Value: ""}, false},
+		{"unknown operator", models.RuleCondition{Field: "source", Operator: "like", Value: "netflix"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var re *regexp.Regexp
+			if tt.c.Operator == models.RuleOpRegex {
+				re = regexp.MustCompile(tt.c.Value)
+			}
+			if got := conditionMatches(tt.c, re, tx); got != tt.want {
+				t.Errorf("conditionMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if conditionMatches(models.RuleCondition{Field: "date", From: "2024-01-01"}, nil, &models.Transaction{}) {
+		t.Error("a date condition matched a transaction without a date")
+	}
+}
+
+func TestRuleEngineApply(t *testing.T) {
+	category, source, paid := "Subscriptions", "Netflix", true
+	contains := func(field, value string) []models.RuleCondition {
+		return []models.RuleCondition{{Field: field, Operator: models.RuleOpContains, Value: value}}
+	}
+	e := &ruleEngine{}
+	for _, r := range []models.Rule{
+		{Name: "rename", Conditions: contains("source", "netflix"), Actions: models.RuleActions{Source: &source}},
+		// Sees the source set by the rule before it
+		{Name: "categorize", Conditions: []models.RuleCondition{{Field: "source", Operator: models.RuleOpEquals, Value: "Netflix"}},
+			Actions: models.RuleActions{Category: &category}, Stop: true},
+		{Name: "after stop", Conditions: contains("source", "netflix"), Actions: models.RuleActions{IsPaid: &paid}},
+		{Name: "no conditions", Actions: models.RuleActions{IsPaid: &paid}},
+	} {
+		e.rules = append(e.rules, compiledRule{Rule: r, regexes: make([]*regexp.Regexp, len(r.Conditions))})
+	}
+
+	tx := &models.Transaction{Source: "NETFLIX.COM 123"}
+	matched := e.apply(tx)
+	if !slices.Equal(matched, []string{"rename", "categorize"}) {
+		t.Errorf("matched = %v", matched)
+	}
+	if tx.Source != "Netflix" || tx.Category != "Subscriptions" || tx.IsPaid {
+		t.Errorf("transaction = %+v", tx)
+	}
+}
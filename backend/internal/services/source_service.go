@@ -0,0 +1,497 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+const sourceColumns = `id, name, aliases,
+	(SELECT COUNT(*) FROM transactions t WHERE t.source = sources.name AND t.deleted_at IS NULL) AS transaction_count,
+	created_at, updated_at`
+
+func scanSource(s rowScanner) (*models.Source, error) {
+	var src models.Source
+	var aliases string
+	if err := s.Scan(&src.ID, &src.Name, &aliases, &src.TransactionCount, &src.CreatedAt, &src.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(aliases), &src.Aliases); err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
+
+func GetAllSources() ([]models.Source, error) {
+	rows, err := db.DB.Query("SELECT " + sourceColumns + " FROM sources ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := []models.Source{}
+	for rows.Next() {
+		src, err := scanSource(rows)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, *src)
+	}
+	return sources, rows.Err()
+}
+
+func GetSourceByID(id string) (*models.Source, error) {
+	src, err := scanSource(db.DB.QueryRow("SELECT "+sourceColumns+" FROM sources WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return src, err
+}
+
+func FindSourceByName(name string) (*models.Source, error) {
+	src, err := scanSource(db.DB.QueryRow("SELECT "+sourceColumns+" FROM sources WHERE name = ?", name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return src, err
+}
+
+func CreateSource(req models.SourceRequest) (*models.Source, error) {
+	aliases, err := json.Marshal(req.Aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	id := uuid.New().String()
+	_, err = db.DB.Exec(`
+		INSERT INTO sources (id, name, aliases, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, id, req.Name, string(aliases), now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetSourceByID(id)
+}
+
+// UpdateSource replaces a source's name and aliases; a new name is also
+// applied to every transaction and recurring pattern using the old one, and
+// the old name is kept as an exact alias. It returns nil when the source does
+// not exist.
+func UpdateSource(id string, req models.SourceRequest, actor string) (*models.Source, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.QueryRow("SELECT name FROM sources WHERE id = ?", id).Scan(&oldName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := req.Name != oldName
+	if renamed && !hasExactAlias(req.Aliases, oldName) {
+		req.Aliases = append(req.Aliases, models.SourceAlias{Match: models.AliasExact, Pattern: oldName})
+	}
+	aliases, err := json.Marshal(req.Aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sources SET name = ?, aliases = ?, updated_at = ? WHERE id = ?
+	`, req.Name, string(aliases), time.Now().Unix(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if renamed {
+		if err := renameSourceReferences(tx, oldName, req.Name, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if renamed {
+		TriggerRecurringDetection()
+	}
+	return GetSourceByID(id)
+}
+
+func DeleteSource(id string) error {
+	_, err := db.DB.Exec("DELETE FROM sources WHERE id = ?", id)
+	return err
+}
+
+// renameSourceReferences points transactions, rules and recurring patterns
+// using one source name at another; the transactions' history records it as
+// a change by actor's rename
+func renameSourceReferences(tx *sql.Tx, oldName, newName, actor string) error {
+	if err := renameTransactions(tx, "source", oldName, newName, models.SystemActor("sources", actor)); err != nil {
+		return err
+	}
+	if err := renameRuleActions(tx, "source", oldName, newName); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE recurring_patterns SET source = ?, updated_at = ? WHERE source = ?", newName, time.Now().Unix(), oldName)
+	return err
+}
+
+// hasExactAlias reports whether the aliases already map name as a whole
+func hasExactAlias(aliases []models.SourceAlias, name string) bool {
+	for _, a := range aliases {
+		if a.Match == models.AliasExact && strings.EqualFold(a.Pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceMatcher maps imported spellings to canonical source names. Exact
+// matches win over prefixes, longer prefixes over shorter ones, and regular
+// expressions are tried last.
+type sourceMatcher struct {
+	exact    map[string]string
+	prefixes []sourcePattern
+	regexes  []sourcePattern
+}
+
+type sourcePattern struct {
+	prefix string
+	regex  *regexp.Regexp
+	name   string
+}
+
+func loadSourceMatcher(q queryer) (*sourceMatcher, error) {
+	rows, err := q.Query("SELECT name, aliases FROM sources ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := &sourceMatcher{exact: make(map[string]string)}
+	for rows.Next() {
+		var name, aliasesJSON string
+		if err := rows.Scan(&name, &aliasesJSON); err != nil {
+			return nil, err
+		}
+		var aliases []models.SourceAlias
+		if err := json.Unmarshal([]byte(aliasesJSON), &aliases); err != nil {
+			return nil, err
+		}
+
+		// The canonical name is an alias of itself, which fixes its case
+		m.exact[strings.ToLower(name)] = name
+		for _, a := range aliases {
+			switch a.Match {
+			case models.AliasExact:
+				m.exact[strings.ToLower(a.Pattern)] = name
+			case models.AliasPrefix:
+				m.prefixes = append(m.prefixes, sourcePattern{prefix: strings.ToLower(a.Pattern), name: name})
+			case models.AliasRegex:
+				// Patterns are validated when saved
+				if re, err := regexp.Compile(a.Pattern); err == nil {
+					m.regexes = append(m.regexes, sourcePattern{regex: re, name: name})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(m.prefixes, func(i, j int) bool { return len(m.prefixes[i].prefix) > len(m.prefixes[j].prefix) })
+	return m, rows.Err()
+}
+
+// canonical returns the canonical name of a source, if any alias matches it
+func (m *sourceMatcher) canonical(source string) (string, bool) {
+	lower := strings.ToLower(strings.TrimSpace(source))
+	if name, ok := m.exact[lower]; ok {
+		return name, true
+	}
+	for _, p := range m.prefixes {
+		if strings.HasPrefix(lower, p.prefix) {
+			return p.name, true
+		}
+	}
+	for _, p := range m.regexes {
+		if p.regex.MatchString(source) {
+			return p.name, true
+		}
+	}
+	return "", false
+}
+
+// NormalizeSources replaces the sources of parsed transactions with their
+// canonical names
+func NormalizeSources(transactions []models.Transaction) error {
+	m, err := loadSourceMatcher(db.DB)
+	if err != nil {
+		return err
+	}
+	for i := range transactions {
+		if name, ok := m.canonical(transactions[i].Source); ok {
+			transactions[i].Source = name
+		}
+	}
+	return nil
+}
+
+// ApplySourceAliases renames stored transactions whose source matches an
+// alias, recording each change in their history. With dryRun the changes
+// are only reported.
+func ApplySourceAliases(dryRun bool, actor string) (*models.SourceApplyResult, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	m, err := loadSourceMatcher(tx)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := matchingTransactions(tx, models.TransactionFilter{IncludeDuplicates: true})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.SourceApplyResult{DryRun: dryRun, Renames: []models.SourceRename{}}
+	index := make(map[[2]string]int)
+	for i := range transactions {
+		t := &transactions[i]
+		name, ok := m.canonical(t.Source)
+		if !ok || name == t.Source {
+			continue
+		}
+
+		key := [2]string{t.Source, name}
+		r, ok := index[key]
+		if !ok {
+			r = len(result.Renames)
+			index[key] = r
+			result.Renames = append(result.Renames, models.SourceRename{From: t.Source, To: name})
+		}
+		result.Renames[r].Count++
+		result.Updated++
+
+		if !dryRun {
+			if _, err := patchTransaction(tx, t, []string{"source"}, []interface{}{name}, models.HistoryUpdate, models.SystemActor("sources", actor)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if result.Updated > 0 {
+		TriggerRecurringDetection()
+	}
+	return result, nil
+}
+
+// SuggestSourceMerges groups the distinct sources of stored transactions
+// whose normalized spellings are at least minSimilarity alike
+func SuggestSourceMerges(minSimilarity float64) ([]models.SourceSuggestion, error) {
+	rows, err := db.DB.Query(`
+		SELECT source, COUNT(*) FROM transactions
+		WHERE deleted_at IS NULL AND source != ''
+		GROUP BY source
+		ORDER BY COUNT(*) DESC, source
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []models.SourceCount
+	for rows.Next() {
+		var s models.SourceCount
+		if err := rows.Scan(&s.Source, &s.Count); err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(sources))
+	for i, s := range sources {
+		keys[i] = sourceKey(s.Source)
+	}
+
+	// Union-find over every pair similar enough
+	parent := make([]int, len(sources))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	similarity := make(map[int]float64)
+	for i := range sources {
+		for j := i + 1; j < len(sources); j++ {
+			sim := 1.0
+			if keys[i] != keys[j] {
+				sim = jaroWinkler(keys[i], keys[j])
+			}
+			if sim < minSimilarity {
+				continue
+			}
+			ri, rj := find(i), find(j)
+			low := sim
+			for _, r := range []int{ri, rj} {
+				if s, ok := similarity[r]; ok && s < low {
+					low = s
+				}
+			}
+			// Sources are sorted by use, so the lower index is the most used
+			if rj < ri {
+				ri, rj = rj, ri
+			}
+			parent[rj] = ri
+			delete(similarity, rj)
+			similarity[ri] = low
+		}
+	}
+
+	groups := make(map[int]*models.SourceSuggestion)
+	var order []int
+	for i, s := range sources {
+		r := find(i)
+		if r == i && similarity[r] == 0 {
+			continue
+		}
+		g, ok := groups[r]
+		if !ok {
+			g = &models.SourceSuggestion{Name: sources[r].Source, Similarity: math.Round(similarity[r]*100) / 100}
+			groups[r] = g
+			order = append(order, r)
+		}
+		g.Sources = append(g.Sources, s)
+	}
+
+	suggestions := []models.SourceSuggestion{}
+	for _, r := range order {
+		suggestions = append(suggestions, *groups[r])
+	}
+	return suggestions, nil
+}
+
+// sourceKey reduces a source to the words that identify the merchant:
+// lowercase, without punctuation, domain suffixes, company forms and card
+// terminal numbers
+func sourceKey(source string) string {
+	fields := strings.FieldsFunc(strings.ToLower(source), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var words []string
+	for _, f := range fields {
+		if len([]rune(f)) < 2 || sourceStopWords[f] || strings.IndexFunc(f, unicode.IsDigit) >= 0 {
+			continue
+		}
+		words = append(words, f)
+	}
+	if len(words) == 0 {
+		return strings.ToLower(strings.TrimSpace(source))
+	}
+	return strings.Join(words, " ")
+}
+
+var sourceStopWords = map[string]bool{
+	"www": true, "com": true, "pl": true, "net": true, "org": true, "eu": true,
+	"intl": true, "international": true, "inc": true, "ltd": true, "llc": true,
+	"gmbh": true, "sa": true, "sp": true, "oo": true,
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings, from 0 for
+// nothing in common to 1 for equal strings
+func jaroWinkler(a, b string) float64 {
+	s1, s2 := []rune(a), []rune(b)
+	if len(s1) == 0 || len(s2) == 0 {
+		return 0
+	}
+
+	window := len(s1)
+	if len(s2) > window {
+		window = len(s2)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matched1 := make([]bool, len(s1))
+	matched2 := make([]bool, len(s2))
+	matches := 0
+	for i := range s1 {
+		lo, hi := i-window, i+window+1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(s2) {
+			hi = len(s2)
+		}
+		for j := lo; j < hi; j++ {
+			if !matched2[j] && s1[i] == s2[j] {
+				matched1[i], matched2[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range s1 {
+		if !matched1[i] {
+			continue
+		}
+		for !matched2[j] {
+			j++
+		}
+		if s1[i] != s2[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) + m/float64(len(s2)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < 4 && prefix < len(s1) && prefix < len(s2) && s1[prefix] == s2[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
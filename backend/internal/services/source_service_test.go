@@ -0,0 +1,84 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+func TestSourceMatcherCanonical(t *testing.T) {
+	openTestDB(t)
+	sources := []models.SourceRequest{
+		{Name: "Netflix", Aliases: []models.SourceAlias{
+			{Match: models.AliasExact, Pattern: "NETFLIX.COM"},
+			{Match: models.AliasRegex, Pattern: `(?i)^nflx\b`},
+		}},
+		{Name: "Allegro", Aliases: []models.SourceAlias{{Match: models.AliasPrefix, Pattern: "allegro"}}},
+		{Name: "Allegro Pay", Aliases: []models.SourceAlias{{Match: models.AliasPrefix, Pattern: "allegro pay"}}},
+		{Name: "Orlen", Aliases: []models.SourceAlias{
+			{Match: models.AliasRegex, Pattern: `^ORLEN STACJA \d+`},
+			{Match: models.AliasExact, Pattern: "allegro pay 123"},
+		}},
+	}
+	for _, req := range sources {
+		if _, err := CreateSource(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := loadSourceMatcher(db.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source string
+		want   string
+		ok     bool
+	}{
+		{"netflix", "Netflix", true},
+		{" Netflix.com ", "Netflix", true},
+		{"NFLX*subscription", "Netflix", true},
+		{"ALLEGRO.PL 12345", "Allegro", true},
+		{"Allegro Pay spłata", "Allegro Pay", true},
+		{"allegro pay 123", "Orlen", true},
+		{"ORLEN STACJA 4021 WARSZAWA", "Orlen", true},
+		{"orlen stacja 4021", "", false},
+		{"Biedronka", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, ok := m.canonical(tt.source)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("canonical(%q) = %q, %v, want %q, %v", tt.source, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"martha", "martha", 1},
+		{"martha", "marhta", 0.9611},
+		{"dixon", "dicksonx", 0.8133},
+		{"dwayne", "duane", 0.84},
+		{"jellyfish", "smellyfish", 0.8963},
+		{"żabka", "zabka", 0.8667},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := jaroWinkler(tt.a, tt.b); math.Abs(got-tt.want) > 0.0001 {
+				t.Errorf("jaroWinkler(%q, %q) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+			}
+			if got, reverse := jaroWinkler(tt.a, tt.b), jaroWinkler(tt.b, tt.a); math.Abs(got-reverse) > 1e-9 {
+				t.Errorf("not symmetric: %.4f and %.4f", got, reverse)
+			}
+		})
+	}
+}
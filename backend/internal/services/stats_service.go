@@ -1,57 +1,74 @@
 package services
 
 import (
+	"sort"
+
 	"kiro-finance-backend/internal/db"
 	"kiro-finance-backend/internal/models"
 )
 
+// Stats are reported in the base currency; amounts without a known
+// exchange rate are left out of the sums (SUM skips NULL)
+const baseAmount = "t.amount_base"
+
 func GetPaymentSummary(filter models.TransactionFilter) (*models.PaymentSummary, error) {
 	whereClause, args := buildWhereClause(filter)
 
 	query := `
 		SELECT 
-			COALESCE(SUM(t.amount), 0) as total,
-			COALESCE(SUM(CASE WHEN t.is_paid = 1 THEN t.amount ELSE 0 END), 0) as paid,
-			COALESCE(SUM(CASE WHEN t.is_paid = 0 THEN t.amount ELSE 0 END), 0) as unpaid,
-			COALESCE(SUM(CASE WHEN t.is_paid = 1 THEN 1 ELSE 0 END), 0) as paid_count,
-			COALESCE(SUM(CASE WHEN t.is_paid = 0 THEN 1 ELSE 0 END), 0) as unpaid_count
+			COALESCE(SUM(CASE WHEN t.direction = 'debit' THEN ` + baseAmount + ` ELSE 0 END), 0) as total,
+			COALESCE(SUM(CASE WHEN t.direction = 'credit' THEN ` + baseAmount + ` ELSE 0 END), 0) as income,
+			COALESCE(SUM(CASE WHEN t.direction = 'debit' AND t.is_paid = 1 THEN ` + baseAmount + ` ELSE 0 END), 0) as paid,
+			COALESCE(SUM(CASE WHEN t.direction = 'debit' AND t.is_paid = 0 THEN ` + baseAmount + ` ELSE 0 END), 0) as unpaid,
+			COALESCE(SUM(CASE WHEN t.direction = 'debit' AND t.is_paid = 1 THEN 1 ELSE 0 END), 0) as paid_count,
+			COALESCE(SUM(CASE WHEN t.direction = 'debit' AND t.is_paid = 0 THEN 1 ELSE 0 END), 0) as unpaid_count,
+			COALESCE(SUM(CASE WHEN ` + baseAmount + ` IS NULL THEN 1 ELSE 0 END), 0) as unconverted_count
 		FROM transactions t
 		LEFT JOIN files f ON t.file_id = f.id
 	` + whereClause
 
-	var summary models.PaymentSummary
+	summary := models.PaymentSummary{Currency: BaseCurrency()}
 	err := db.DB.QueryRow(query, args...).Scan(
 		&summary.TotalSpent,
+		&summary.TotalIncome,
 		&summary.PaidAmount,
 		&summary.UnpaidAmount,
 		&summary.PaidCount,
 		&summary.UnpaidCount,
+		&summary.UnconvertedCount,
 	)
 
 	if err != nil {
 		return nil, err
 	}
+	summary.NetCashFlow = summary.TotalIncome - summary.TotalSpent
 
 	return &summary, nil
 }
 
-func GetCategoryTotals(filter models.TransactionFilter) ([]models.CategoryTotal, error) {
+// GetCategoryTotals sums transactions per category. With level > 0 each
+// category is counted under its ancestor at that depth of the hierarchy, 1
+// being top-level categories; shallower ones are kept as they are.
+func GetCategoryTotals(filter models.TransactionFilter, level int) ([]models.CategoryTotal, error) {
 	whereClause, args := buildWhereClause(filter)
 
-	// First get total for percentage calculation
+	// First get total expenses for percentage calculation
 	totalQuery := `
-		SELECT COALESCE(SUM(t.amount), 0)
+		SELECT COALESCE(SUM(CASE WHEN t.direction = 'debit' THEN ` + baseAmount + ` ELSE 0 END), 0)
 		FROM transactions t
 		LEFT JOIN files f ON t.file_id = f.id
 	` + whereClause
 
-	var total float64
+	var total models.Money
 	if err := db.DB.QueryRow(totalQuery, args...).Scan(&total); err != nil {
 		return nil, err
 	}
 
 	query := `
-		SELECT t.category, SUM(t.amount) as total, COUNT(*) as count
+		SELECT t.category,
+			COALESCE(SUM(CASE WHEN t.direction = 'debit' THEN ` + baseAmount + ` ELSE 0 END), 0) as total,
+			COALESCE(SUM(CASE WHEN t.direction = 'credit' THEN ` + baseAmount + ` ELSE 0 END), 0) as income,
+			COUNT(*) as count
 		FROM transactions t
 		LEFT JOIN files f ON t.file_id = f.id
 	` + whereClause + `
@@ -68,35 +85,125 @@ func GetCategoryTotals(filter models.TransactionFilter) ([]models.CategoryTotal,
 	var categories []models.CategoryTotal
 	for rows.Next() {
 		var c models.CategoryTotal
-		if err := rows.Scan(&c.Category, &c.Total, &c.Count); err != nil {
+		if err := rows.Scan(&c.Category, &c.Total, &c.Income, &c.Count); err != nil {
 			return nil, err
 		}
+		c.Net = c.Income - c.Total
 		if total > 0 {
-			c.Percentage = (c.Total / total) * 100
+			c.Percentage = float64(c.Total) / float64(total) * 100
 		}
 		categories = append(categories, c)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	if level > 0 {
+		return rollUpCategoryTotals(categories, level, total)
+	}
 	return categories, nil
 }
 
+func rollUpCategoryTotals(totals []models.CategoryTotal, level int, total models.Money) ([]models.CategoryTotal, error) {
+	categories, err := loadCategories(db.DB)
+	if err != nil {
+		return nil, err
+	}
+	idByName := make(map[string]string, len(categories))
+	for id, c := range categories {
+		idByName[c.Name] = id
+	}
+
+	var rolledUp []models.CategoryTotal
+	index := make(map[string]int)
+	for _, c := range totals {
+		name := c.Category
+		if path := categoryPath(categories, idByName[name]); len(path) > level {
+			name = path[level-1].Name
+		}
+
+		i, ok := index[name]
+		if !ok {
+			i = len(rolledUp)
+			index[name] = i
+			rolledUp = append(rolledUp, models.CategoryTotal{Category: name})
+		}
+		rolledUp[i].Total += c.Total
+		rolledUp[i].Income += c.Income
+		rolledUp[i].Net += c.Net
+		rolledUp[i].Count += c.Count
+	}
+
+	for i := range rolledUp {
+		if total > 0 {
+			rolledUp[i].Percentage = float64(rolledUp[i].Total) / float64(total) * 100
+		}
+	}
+	sort.SliceStable(rolledUp, func(i, j int) bool { return rolledUp[i].Total > rolledUp[j].Total })
+	return rolledUp, nil
+}
+
+// CategoryTotalsFromTransactions aggregates parsed transactions that are
+// not stored yet, e.g. for an import preview. Like the stored stats it sums
+// AmountBase (see ConvertToBase), leaving out amounts without a rate.
+func CategoryTotalsFromTransactions(transactions []models.Transaction) []models.CategoryTotal {
+	var total models.Money
+	index := make(map[string]int)
+	categories := []models.CategoryTotal{}
+
+	for _, t := range transactions {
+		i, ok := index[t.Category]
+		if !ok {
+			i = len(categories)
+			index[t.Category] = i
+			categories = append(categories, models.CategoryTotal{Category: t.Category})
+		}
+		categories[i].Count++
+		if t.AmountBase == nil {
+			continue
+		}
+		if t.Direction == models.DirectionCredit {
+			categories[i].Income += *t.AmountBase
+		} else {
+			total += *t.AmountBase
+			categories[i].Total += *t.AmountBase
+		}
+	}
+
+	for i := range categories {
+		categories[i].Net = categories[i].Income - categories[i].Total
+		if total > 0 {
+			categories[i].Percentage = float64(categories[i].Total) / float64(total) * 100
+		}
+	}
+
+	sort.SliceStable(categories, func(i, j int) bool {
+		return categories[i].Total > categories[j].Total
+	})
+
+	return categories
+}
+
 func GetSourceTotals(filter models.TransactionFilter) ([]models.SourceTotal, error) {
 	whereClause, args := buildWhereClause(filter)
 
-	// First get total for percentage calculation
+	// First get total expenses for percentage calculation
 	totalQuery := `
-		SELECT COALESCE(SUM(t.amount), 0)
+		SELECT COALESCE(SUM(CASE WHEN t.direction = 'debit' THEN ` + baseAmount + ` ELSE 0 END), 0)
 		FROM transactions t
 		LEFT JOIN files f ON t.file_id = f.id
 	` + whereClause
 
-	var total float64
+	var total models.Money
 	if err := db.DB.QueryRow(totalQuery, args...).Scan(&total); err != nil {
 		return nil, err
 	}
 
 	query := `
-		SELECT t.source, SUM(t.amount) as total, COUNT(*) as count
+		SELECT t.source,
+			COALESCE(SUM(CASE WHEN t.direction = 'debit' THEN ` + baseAmount + ` ELSE 0 END), 0) as total,
+			COALESCE(SUM(CASE WHEN t.direction = 'credit' THEN ` + baseAmount + ` ELSE 0 END), 0) as income,
+			COUNT(*) as count
 		FROM transactions t
 		LEFT JOIN files f ON t.file_id = f.id
 	` + whereClause + `
@@ -113,11 +220,12 @@ func GetSourceTotals(filter models.TransactionFilter) ([]models.SourceTotal, err
 	var sources []models.SourceTotal
 	for rows.Next() {
 		var s models.SourceTotal
-		if err := rows.Scan(&s.Source, &s.Total, &s.Count); err != nil {
+		if err := rows.Scan(&s.Source, &s.Total, &s.Income, &s.Count); err != nil {
 			return nil, err
 		}
+		s.Net = s.Income - s.Total
 		if total > 0 {
-			s.Percentage = (s.Total / total) * 100
+			s.Percentage = float64(s.Total) / float64(total) * 100
 		}
 		sources = append(sources, s)
 	}
@@ -125,8 +233,60 @@ func GetSourceTotals(filter models.TransactionFilter) ([]models.SourceTotal, err
 	return sources, nil
 }
 
+// GetTagTotals sums the transactions matching the filter per tag; untagged
+// ones are left out, but still count towards the percentage base
+func GetTagTotals(filter models.TransactionFilter) ([]models.TagTotal, error) {
+	whereClause, args := buildWhereClause(filter)
+
+	totalQuery := `
+		SELECT COALESCE(SUM(CASE WHEN t.direction = 'debit' THEN ` + baseAmount + ` ELSE 0 END), 0)
+		FROM transactions t
+		LEFT JOIN files f ON t.file_id = f.id
+	` + whereClause
+
+	var total models.Money
+	if err := db.DB.QueryRow(totalQuery, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT tg.name,
+			COALESCE(SUM(CASE WHEN t.direction = 'debit' THEN ` + baseAmount + ` ELSE 0 END), 0) as total,
+			COALESCE(SUM(CASE WHEN t.direction = 'credit' THEN ` + baseAmount + ` ELSE 0 END), 0) as income,
+			COUNT(*) as count
+		FROM transactions t
+		LEFT JOIN files f ON t.file_id = f.id
+		JOIN transaction_tags tt ON tt.transaction_id = t.id
+		JOIN tags tg ON tg.id = tt.tag_id
+	` + whereClause + `
+		GROUP BY tg.name
+		ORDER BY total DESC, tg.name
+	`
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.TagTotal
+	for rows.Next() {
+		var tag models.TagTotal
+		if err := rows.Scan(&tag.Tag, &tag.Total, &tag.Income, &tag.Count); err != nil {
+			return nil, err
+		}
+		tag.Net = tag.Income - tag.Total
+		if total > 0 {
+			tag.Percentage = float64(tag.Total) / float64(total) * 100
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
 func GetTopCategory(filter models.TransactionFilter) (*models.CategoryTotal, error) {
-	categories, err := GetCategoryTotals(filter)
+	categories, err := GetCategoryTotals(filter, 0)
 	if err != nil {
 		return nil, err
 	}
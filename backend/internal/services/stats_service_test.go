@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+
+	"kiro-finance-backend/internal/models"
+)
+
+func TestStatsLeaveOutUnconvertedAmounts(t *testing.T) {
+	openTestDB(t)
+	addTestFile(t, "f1")
+
+	// No EUR rate is loaded, so the hotel has no base amount
+	err := SaveTransactions([]models.Transaction{
+		{ID: "t1", FileID: "f1", Category: "Food", Source: "Shop", Amount: 1250, Direction: models.DirectionDebit, Currency: "PLN"},
+		{ID: "t2", FileID: "f1", Category: "Travel", Source: "Hotel", Amount: 4900, Direction: models.DirectionDebit, Currency: "EUR", Tags: []string{"trip"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := GetPaymentSummary(models.TransactionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalSpent != 1250 || summary.UnconvertedCount != 1 {
+		t.Errorf("summary = %+v, want totalSpent 1250 and 1 unconverted", summary)
+	}
+
+	categories, err := GetCategoryTotals(models.TransactionFilter{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]models.Money{"Food": 1250, "Travel": 0}
+	if len(categories) != len(want) {
+		t.Fatalf("categories = %+v", categories)
+	}
+	for _, c := range categories {
+		if total, ok := want[c.Category]; !ok || c.Total != total || c.Count != 1 {
+			t.Errorf("category %q: total %d, count %d", c.Category, c.Total, c.Count)
+		}
+	}
+
+	sources, err := GetSourceTotals(models.TransactionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 {
+		t.Errorf("sources = %+v", sources)
+	}
+
+	tags, err := GetTagTotals(models.TransactionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Tag != "trip" || tags[0].Total != 0 {
+		t.Errorf("tags = %+v, want trip with total 0", tags)
+	}
+
+	top, err := GetTopCategory(models.TransactionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top == nil || top.Category != "Food" {
+		t.Errorf("top category = %+v, want Food", top)
+	}
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"kiro-finance-backend/internal/config"
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+// correctionWeight is how many ordinary transactions a category set by hand
+// counts as when training
+const correctionWeight = 3
+
+// A category is often corrected when at least correctedMinCount
+// transactions were moved out of it by hand, making up at least
+// correctedMinShare of those that had it
+const (
+	correctedMinCount = 3
+	correctedMinShare = 0.3
+)
+
+// correctionSQL holds for history rows h of hand edits to the category,
+// leaving out automated runs and undone edits
+const correctionSQL = `h.action = ? AND h.undone_at IS NULL
+	AND ',' || h.fields || ',' LIKE '%,category,%' AND h.actor NOT LIKE ? || '%'`
+
+// categoryClassifier is a multinomial naive Bayes classifier over the
+// source and description words of transactions
+type categoryClassifier struct {
+	docs   map[string]float64            // weighted transactions per category
+	counts map[string]map[string]float64 // weighted word counts per category
+	words  map[string]float64            // weighted word total per category
+	vocab  map[string]bool
+	total  float64
+}
+
+// trainCategoryClassifier learns from every categorized transaction except
+// excludeID; categories corrected by hand (see correctionSQL) weigh more
+func trainCategoryClassifier(q queryer, excludeID string) (*categoryClassifier, error) {
+	rows, err := q.Query(`
+		SELECT t.source, t.description, t.category, EXISTS (
+			SELECT 1 FROM transaction_history h
+			WHERE h.transaction_id = t.id AND `+correctionSQL+`
+				AND json_extract(h.after_state, '$.category') = t.category
+		)
+		FROM transactions t
+		WHERE t.category != '' AND t.duplicate_of IS NULL AND t.deleted_at IS NULL AND t.id != ?
+	`, models.HistoryUpdate, models.SystemActorPrefix, excludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := &categoryClassifier{
+		docs:   make(map[string]float64),
+		counts: make(map[string]map[string]float64),
+		words:  make(map[string]float64),
+		vocab:  make(map[string]bool),
+	}
+	for rows.Next() {
+		var source, description, category string
+		var corrected bool
+		if err := rows.Scan(&source, &description, &category, &corrected); err != nil {
+			return nil, err
+		}
+
+		weight := 1.0
+		if corrected {
+			weight = correctionWeight
+		}
+		tokens := suggestionTokens(source, description)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		m.total += weight
+		m.docs[category] += weight
+		if m.counts[category] == nil {
+			m.counts[category] = make(map[string]float64)
+		}
+		for _, token := range tokens {
+			m.counts[category][token] += weight
+			m.words[category] += weight
+			m.vocab[token] = true
+		}
+	}
+	return m, rows.Err()
+}
+
+// predict returns the categories in order of probability, or nothing when
+// none of the words has been seen before
+func (m *categoryClassifier) predict(source, description string) []models.CategorySuggestion {
+	var known []string
+	for _, token := range suggestionTokens(source, description) {
+		if m.vocab[token] {
+			known = append(known, token)
+		}
+	}
+	if len(known) == 0 {
+		return nil
+	}
+
+	// Log probabilities with add-one smoothing, normalized with log-sum-exp
+	vocab := float64(len(m.vocab))
+	scores := make(map[string]float64, len(m.docs))
+	best := math.Inf(-1)
+	for category, docs := range m.docs {
+		score := math.Log(docs / m.total)
+		for _, token := range known {
+			score += math.Log((m.counts[category][token] + 1) / (m.words[category] + vocab))
+		}
+		scores[category] = score
+		if score > best {
+			best = score
+		}
+	}
+	var sum float64
+	for _, score := range scores {
+		sum += math.Exp(score - best)
+	}
+
+	suggestions := make([]models.CategorySuggestion, 0, len(scores))
+	for category, score := range scores {
+		suggestions = append(suggestions, models.CategorySuggestion{
+			Category:   category,
+			Confidence: math.Exp(score-best) / sum,
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].Category < suggestions[j].Category
+	})
+	return suggestions
+}
+
+// suggestionTokens lists the lowercase words of a source and description,
+// kept apart so the same word can weigh differently in each; numbers such
+// as dates and card terminals are left out
+func suggestionTokens(source, description string) []string {
+	var tokens []string
+	for _, field := range []struct{ prefix, text string }{{"s:", source}, {"d:", description}} {
+		words := strings.FieldsFunc(strings.ToLower(field.text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, w := range words {
+			if len([]rune(w)) < 2 || strings.IndexFunc(w, unicode.IsDigit) >= 0 {
+				continue
+			}
+			tokens = append(tokens, field.prefix+w)
+		}
+	}
+	return tokens
+}
+
+// SuggestCategories returns up to limit likely categories of a stored
+// transaction, learned from the others. It returns nil when the transaction
+// does not exist or is in the trash.
+func SuggestCategories(id string, limit int) ([]models.CategorySuggestion, error) {
+	t, err := getTransaction(db.DB, id)
+	if err == sql.ErrNoRows || (err == nil && t.DeletedAt != nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := trainCategoryClassifier(db.DB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := m.predict(t.Source, t.Description)
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	if suggestions == nil {
+		suggestions = []models.CategorySuggestion{}
+	}
+	return suggestions, nil
+}
+
+// oftenCorrectedCategories returns the categories often moved away from by
+// hand (see correctedMinCount)
+func oftenCorrectedCategories(q queryer) (map[string]bool, error) {
+	rows, err := q.Query(`
+		SELECT c.category, c.corrected, (
+			SELECT COUNT(*) FROM transactions
+			WHERE category = c.category AND duplicate_of IS NULL AND deleted_at IS NULL
+		)
+		FROM (
+			SELECT json_extract(h.before_state, '$.category') AS category, COUNT(DISTINCT h.transaction_id) AS corrected
+			FROM transaction_history h
+			JOIN transactions t ON t.id = h.transaction_id
+			WHERE t.deleted_at IS NULL AND `+correctionSQL+`
+			GROUP BY 1
+		) c
+		WHERE c.category != ''
+	`, models.HistoryUpdate, models.SystemActorPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[string]bool)
+	for rows.Next() {
+		var category string
+		var corrected, current int
+		if err := rows.Scan(&category, &corrected, &current); err != nil {
+			return nil, err
+		}
+		if corrected >= correctedMinCount && float64(corrected) >= correctedMinShare*float64(corrected+current) {
+			categories[category] = true
+		}
+	}
+	return categories, rows.Err()
+}
+
+// AutoCategorize sets the category of parsed transactions whose category is
+// empty or often corrected by hand to the best suggestion, when it reaches
+// the configured confidence
+func AutoCategorize(transactions []models.Transaction) error {
+	if config.Cfg == nil || config.Cfg.CategoryAutoApply <= 0 {
+		return nil
+	}
+
+	var m *categoryClassifier
+	var corrected map[string]bool
+	for i := range transactions {
+		t := &transactions[i]
+		if t.Category != "" {
+			if corrected == nil {
+				var err error
+				if corrected, err = oftenCorrectedCategories(db.DB); err != nil {
+					return err
+				}
+			}
+			if !corrected[t.Category] {
+				continue
+			}
+		}
+		if m == nil {
+			var err error
+			if m, err = trainCategoryClassifier(db.DB, ""); err != nil {
+				return err
+			}
+		}
+		if suggestions := m.predict(t.Source, t.Description); len(suggestions) > 0 && suggestions[0].Confidence >= config.Cfg.CategoryAutoApply {
+			t.Category = suggestions[0].Category
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+func TestCategoryClassifierPredict(t *testing.T) {
+	openTestDB(t)
+	addTestFile(t, "f1")
+	var stored []models.Transaction
+	for i, s := range []struct{ source, description, category string }{
+		{"Biedronka 1021", "Zakupy", "Food"},
+		{"BIEDRONKA 2291", "Zakupy spożywcze", "Food"},
+		{"Biedronka", "Chleb", "Food"},
+		{"Orlen Stacja 12", "Paliwo", "Transport"},
+		{"ORLEN", "Paliwo PB95", "Transport"},
+		{"Netflix.com", "Subskrypcja", "Entertainment"},
+		{"Żabka", "Hot dog", "Food"},
+		{"Żabka", "Cola", "Food"},
+		{"Empik", "", ""},
+	} {
+		tx := testTransaction(string(rune('a'+i)), "f1", s.source, 1000, int64(i))
+		tx.Description, tx.Category = s.description, s.category
+		stored = append(stored, tx)
+	}
+	if err := SaveTransactions(stored); err != nil {
+		t.Fatal(err)
+	}
+	// Corrected by hand, so it outweighs the other Żabka purchase
+	snacks := "Snacks"
+	if _, err := UpdateTransaction("h", models.TransactionPatch{Category: &snacks}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := trainCategoryClassifier(db.DB, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		source      string
+		description string
+		want        string // best category, "" for no suggestions
+	}{
+		{"known source", "BIEDRONKA 7731", "", "Food"},
+		{"known description", "Stacja Shell", "paliwo", "Transport"},
+		{"source and description", "netflix", "subskrypcja luty", "Entertainment"},
+		{"hand correction weighs more", "Żabka", "", "Snacks"},
+		{"unseen words", "Rossmann", "Kosmetyki", ""},
+		{"numbers only", "1234", "2024-01-05", ""},
+		{"uncategorized are not learned", "Empik", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions := m.predict(tt.source, tt.description)
+			if tt.want == "" {
+				if len(suggestions) != 0 {
+					t.Errorf("predict() = %+v, want none", suggestions)
+				}
+				return
+			}
+			if len(suggestions) == 0 || suggestions[0].Category != tt.want {
+				t.Fatalf("predict() = %+v, want %s first", suggestions, tt.want)
+			}
+			sum := 0.0
+			for i, s := range suggestions {
+				sum += s.Confidence
+				if i > 0 && s.Confidence > suggestions[i-1].Confidence {
+					t.Errorf("suggestions out of order: %+v", suggestions)
+				}
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("confidences sum to %f", sum)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+const tagColumns = `id, name, color,
+	(SELECT COUNT(*) FROM transaction_tags tt JOIN transactions t ON t.id = tt.transaction_id
+		WHERE tt.tag_id = tags.id AND t.deleted_at IS NULL) AS transaction_count,
+	created_at, updated_at`
+
+func scanTag(s rowScanner) (*models.Tag, error) {
+	var tag models.Tag
+	if err := s.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.TransactionCount, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func GetAllTags() ([]models.Tag, error) {
+	rows, err := db.DB.Query("SELECT " + tagColumns + " FROM tags ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, rows.Err()
+}
+
+func GetTagByID(id string) (*models.Tag, error) {
+	tag, err := scanTag(db.DB.QueryRow("SELECT "+tagColumns+" FROM tags WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return tag, err
+}
+
+func FindTagByName(name string) (*models.Tag, error) {
+	tag, err := scanTag(db.DB.QueryRow("SELECT "+tagColumns+" FROM tags WHERE name = ?", name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return tag, err
+}
+
+func CreateTag(req models.TagRequest) (*models.Tag, error) {
+	now := time.Now().Unix()
+	id := uuid.New().String()
+	_, err := db.DB.Exec(`
+		INSERT INTO tags (id, name, color, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+	`, id, req.Name, req.Color, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetTagByID(id)
+}
+
+// UpdateTag renames or recolors a tag; transactions refer to it by ID, so
+// they follow. It returns nil when the tag does not exist.
+func UpdateTag(id string, req models.TagRequest) (*models.Tag, error) {
+	result, err := db.DB.Exec(`
+		UPDATE tags SET name = ?, color = ?, updated_at = ? WHERE id = ?
+	`, req.Name, req.Color, time.Now().Unix(), id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+
+	return GetTagByID(id)
+}
+
+// DeleteTag removes a tag from every transaction and deletes it
+func DeleteTag(id string) error {
+	_, err := db.DB.Exec("DELETE FROM tags WHERE id = ?", id)
+	return err
+}
+
+// registerTags adds tags for names not seen before
+func registerTags(exec execer, names ...string) error {
+	now := time.Now().Unix()
+	for _, name := range names {
+		if _, err := exec.Exec(`
+			INSERT OR IGNORE INTO tags (id, name, created_at, updated_at) VALUES (?, ?, ?, ?)
+		`, uuid.New().String(), name, now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachTags tags a transaction, creating tags that do not exist yet
+func attachTags(exec execer, transactionID string, names []string) error {
+	if err := registerTags(exec, names...); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, name := range names {
+		if _, err := exec.Exec(`
+			INSERT OR IGNORE INTO transaction_tags (transaction_id, tag_id, created_at)
+			SELECT ?, id, ? FROM tags WHERE name = ?
+		`, transactionID, now, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceTags sets the tags of a transaction to exactly the given ones
+func replaceTags(exec execer, transactionID string, names []string) error {
+	if _, err := exec.Exec("DELETE FROM transaction_tags WHERE transaction_id = ?", transactionID); err != nil {
+		return err
+	}
+	return attachTags(exec, transactionID, names)
+}
+
+// recordTagChange records in the history how a transaction's tags changed
+// since before, if they did, and returns the transaction as it is now
+func recordTagChange(q queryExecer, before *models.Transaction, action, actor string) (*models.Transaction, error) {
+	after, err := getTransaction(q, before.ID)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Equal(before.Tags, after.Tags) {
+		return after, nil
+	}
+	return after, recordHistory(q, action, []string{"tags"}, before, after, actor)
+}
+
+// AttachTransactionTags tags a transaction and returns it, or nil when it
+// does not exist or is in the trash
+func AttachTransactionTags(id string, names []string, actor string) (*models.Transaction, error) {
+	return changeTransactionTags(id, actor, func(tx *sql.Tx) error {
+		return attachTags(tx, id, names)
+	})
+}
+
+// DetachTransactionTags removes tags from a transaction and returns it, or
+// nil when it does not exist or is in the trash; the tags themselves stay
+func DetachTransactionTags(id string, names []string, actor string) (*models.Transaction, error) {
+	return changeTransactionTags(id, actor, func(tx *sql.Tx) error {
+		for _, name := range names {
+			if _, err := tx.Exec(`
+				DELETE FROM transaction_tags
+				WHERE transaction_id = ? AND tag_id = (SELECT id FROM tags WHERE name = ?)
+			`, id, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func changeTransactionTags(id, actor string, change func(tx *sql.Tx) error) (*models.Transaction, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	t, err := getTransaction(tx, id)
+	if err == sql.ErrNoRows || (err == nil && t.DeletedAt != nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := change(tx); err != nil {
+		return nil, err
+	}
+	if t, err = recordTagChange(tx, t, models.HistoryUpdate, actor); err != nil {
+		return nil, err
+	}
+	return t, tx.Commit()
+}
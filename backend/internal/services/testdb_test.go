@@ -0,0 +1,32 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"kiro-finance-backend/internal/config"
+	"kiro-finance-backend/internal/db"
+)
+
+// openTestDB points db.DB at a new database in a temporary directory
+func openTestDB(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	config.Cfg = &config.Config{
+		DBPath:       filepath.Join(dir, "finance.db"),
+		BaseCurrency: "PLN",
+		UploadsDir:   filepath.Join(dir, "uploads"),
+	}
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+}
+
+// addTestFile stores an empty file for test transactions to belong to
+func addTestFile(t *testing.T, id string) {
+	t.Helper()
+	if _, err := db.DB.Exec("INSERT INTO files (id, name, uploaded_at, created_at, updated_at) VALUES (?, ?, 0, 0, 0)", id, id+".csv"); err != nil {
+		t.Fatal(err)
+	}
+}
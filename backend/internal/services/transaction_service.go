@@ -1,13 +1,47 @@
 package services
 
 import (
+	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"kiro-finance-backend/internal/db"
 	"kiro-finance-backend/internal/models"
 )
 
+const transactionColumns = `t.id, t.file_id, t.category, t.source, t.description, t.amount, t.amount_original,
+	t.direction, t.currency, t.amount_base, t.is_paid, t.bank, t.transaction_date, t.external_id, t.duplicate_of, t.created_at, t.deleted_at,
+	(SELECT group_concat(tg.name, ',') FROM transaction_tags tt JOIN tags tg ON tg.id = tt.tag_id WHERE tt.transaction_id = t.id) AS tags`
+
+// scanTransaction reads a row selected with transactionColumns, followed by
+// any extra columns
+func scanTransaction(s rowScanner, extra ...interface{}) (*models.Transaction, error) {
+	var t models.Transaction
+	var isPaid int
+	var tags sql.NullString
+
+	dest := []interface{}{
+		&t.ID, &t.FileID, &t.Category, &t.Source, &t.Description, &t.Amount, &t.AmountOriginal,
+		&t.Direction, &t.Currency, &t.AmountBase, &isPaid, &t.Bank, &t.TransactionDate, &t.ExternalID, &t.DuplicateOf, &t.CreatedAt, &t.DeletedAt,
+		&tags,
+	}
+	if err := s.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	t.IsPaid = isPaid == 1
+	t.Tags = []string{}
+	if tags.Valid {
+		// Tag names cannot contain commas
+		t.Tags = strings.Split(tags.String, ",")
+		sort.Strings(t.Tags)
+	}
+	return &t, nil
+}
+
 func GetTransactions(filter models.TransactionFilter, page, perPage int) (*models.PaginatedTransactions, error) {
 	whereClause, args := buildWhereClause(filter)
 
@@ -22,8 +56,7 @@ func GetTransactions(filter models.TransactionFilter, page, perPage int) (*model
 	usePagination := page > 0 && perPage > 0
 
 	query := `
-		SELECT t.id, t.file_id, t.category, t.source, t.description, 
-		       t.amount, t.amount_original, t.is_paid, t.bank, t.transaction_date, t.created_at
+		SELECT ` + transactionColumns + `
 		FROM transactions t
 		LEFT JOIN files f ON t.file_id = f.id
 	` + whereClause + " ORDER BY t.created_at DESC"
@@ -42,20 +75,11 @@ func GetTransactions(filter models.TransactionFilter, page, perPage int) (*model
 
 	var transactions []models.Transaction
 	for rows.Next() {
-		var t models.Transaction
-		var isPaid int
-		var transactionDate *string
-
-		if err := rows.Scan(
-			&t.ID, &t.FileID, &t.Category, &t.Source, &t.Description,
-			&t.Amount, &t.AmountOriginal, &isPaid, &t.Bank, &transactionDate, &t.CreatedAt,
-		); err != nil {
+		t, err := scanTransaction(rows)
+		if err != nil {
 			return nil, err
 		}
-
-		t.IsPaid = isPaid == 1
-		t.TransactionDate = transactionDate
-		transactions = append(transactions, t)
+		transactions = append(transactions, *t)
 	}
 
 	// Calculate pagination info
@@ -82,52 +106,374 @@ func GetTransactions(filter models.TransactionFilter, page, perPage int) (*model
 }
 
 func GetTransactionByID(id string) (*models.Transaction, error) {
-	var t models.Transaction
-	var isPaid int
-	var transactionDate *string
+	return getTransaction(db.DB, id)
+}
 
-	err := db.DB.QueryRow(`
-		SELECT id, file_id, category, source, description, amount, amount_original, 
-		       is_paid, bank, transaction_date, created_at
-		FROM transactions WHERE id = ?
-	`, id).Scan(
-		&t.ID, &t.FileID, &t.Category, &t.Source, &t.Description,
-		&t.Amount, &t.AmountOriginal, &isPaid, &t.Bank, &transactionDate, &t.CreatedAt,
-	)
+func getTransaction(q queryExecer, id string) (*models.Transaction, error) {
+	return scanTransaction(q.QueryRow(`
+		SELECT `+transactionColumns+`
+		FROM transactions t WHERE t.id = ?
+	`, id))
+}
 
+// CreateTransactions stores transactions entered by hand in the manual
+// entries file and returns them as stored
+func CreateTransactions(requests []models.TransactionRequest) ([]models.Transaction, error) {
+	now := time.Now().Unix()
+	transactions := make([]models.Transaction, len(requests))
+	for i, req := range requests {
+		transactions[i] = models.Transaction{
+			ID:              uuid.New().String(),
+			FileID:          models.ManualFileID,
+			Category:        req.Category,
+			Source:          req.Source,
+			Description:     req.Description,
+			Amount:          models.Money(req.Amount),
+			AmountOriginal:  models.Money(req.Amount).String(),
+			Direction:       req.Direction,
+			Currency:        req.Currency,
+			IsPaid:          req.IsPaid,
+			Bank:            req.Bank,
+			TransactionDate: req.TransactionDate,
+			Tags:            req.Tags,
+			CreatedAt:       now,
+		}
+	}
+
+	if err := SaveTransactions(transactions); err != nil {
+		return nil, err
+	}
+
+	created := make([]models.Transaction, len(transactions))
+	for i, t := range transactions {
+		stored, err := GetTransactionByID(t.ID)
+		if err != nil {
+			return nil, err
+		}
+		created[i] = *stored
+	}
+	return created, nil
+}
+
+// queryExecer is a *sql.DB or *sql.Tx
+type queryExecer interface {
+	execer
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// UpdateTransaction applies a patch and returns the updated transaction, or
+// nil when it does not exist or is in the trash
+func UpdateTransaction(id string, patch models.TransactionPatch, actor string) (*models.Transaction, error) {
+	tx, err := db.DB.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	t.IsPaid = isPaid == 1
-	t.TransactionDate = transactionDate
-	return &t, nil
+	t, err := getTransaction(tx, id)
+	if err == sql.ErrNoRows || (err == nil && t.DeletedAt != nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns, values := patchColumns(patch)
+	if len(columns) == 0 {
+		return t, nil
+	}
+	if t, err = patchTransaction(tx, t, columns, values, models.HistoryUpdate, actor); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
-func UpdateTransaction(id string, updates map[string]interface{}) error {
-	var setClauses []string
-	var args []interface{}
+// BulkUpdateTransactions applies a patch to every transaction matching the
+// filter in a single database transaction and returns how many were updated
+func BulkUpdateTransactions(filter models.TransactionFilter, patch models.TransactionPatch, actor string) (int, error) {
+	columns, values := patchColumns(patch)
+	if len(columns) == 0 {
+		return 0, nil
+	}
 
-	for key, value := range updates {
-		setClauses = append(setClauses, fmt.Sprintf("%s = ?", key))
-		args = append(args, value)
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	if len(setClauses) == 0 {
-		return nil
+	transactions, err := matchingTransactions(tx, filter)
+	if err != nil {
+		return 0, err
+	}
+	for i := range transactions {
+		if _, err := patchTransaction(tx, &transactions[i], columns, values, models.HistoryUpdate, actor); err != nil {
+			return 0, err
+		}
 	}
 
-	args = append(args, id)
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if len(transactions) > 0 {
+		TriggerRecurringDetection()
+	}
+	return len(transactions), nil
+}
+
+// matchingTransactions loads the transactions a bulk operation applies to
+func matchingTransactions(tx *sql.Tx, filter models.TransactionFilter) ([]models.Transaction, error) {
+	whereClause, args := buildWhereClause(filter)
+	rows, err := tx.Query(`
+		SELECT `+transactionColumns+`
+		FROM transactions t
+		LEFT JOIN files f ON t.file_id = f.id
+	`+whereClause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *t)
+	}
+	return transactions, rows.Err()
+}
+
+// renameTransactions sets a text column to newName on every transaction
+// where it is oldName, trashed ones included, recording each change in the
+// history
+func renameTransactions(tx *sql.Tx, column, oldName, newName, actor string) error {
+	rows, err := tx.Query(`
+		SELECT `+transactionColumns+`
+		FROM transactions t WHERE t.`+column+` = ?
+	`, oldName)
+	if err != nil {
+		return err
+	}
+	var transactions []models.Transaction
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		transactions = append(transactions, *t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range transactions {
+		if _, err := patchTransaction(tx, &transactions[i], []string{column}, []interface{}{newName}, models.HistoryUpdate, actor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchTransaction sets the given columns of one transaction, remembers them
+// as edited by hand so a reimport keeps them, and records the change in the
+// history. Changes by a system actor (see models.SystemActor) are not edits
+// by hand. It returns the transaction as updated.
+func patchTransaction(q queryExecer, before *models.Transaction, columns []string, values []interface{}, action, actor string) (*models.Transaction, error) {
+	var edited string
+	if err := q.QueryRow("SELECT edited_fields FROM transactions WHERE id = ?", before.ID).Scan(&edited); err != nil {
+		return nil, err
+	}
+	editedFields := parseEditedFields(edited)
+	byHand := !strings.HasPrefix(actor, models.SystemActorPrefix)
+
+	var changed []string
+	setClauses := make([]string, len(columns))
+	for i, column := range columns {
+		setClauses[i] = column + " = ?"
+		// amount_original and not_duplicate follow amount and duplicate_of,
+		// which is not read from the file, so a reimport cannot undo it
+		if column != "amount_original" && column != "not_duplicate" {
+			if byHand && column != "duplicate_of" {
+				editedFields[column] = true
+			}
+			changed = append(changed, column)
+		}
+		if category, ok := values[i].(string); ok && column == "category" {
+			if err := registerCategories(q, category); err != nil {
+				return nil, err
+			}
+		}
+	}
+	setClauses = append(setClauses, "edited_fields = ?")
+
+	args := append(append([]interface{}{}, values...), formatEditedFields(editedFields), before.ID)
 	query := fmt.Sprintf("UPDATE transactions SET %s WHERE id = ?", strings.Join(setClauses, ", "))
-	_, err := db.DB.Exec(query, args...)
-	return err
+	if _, err := q.Exec(query, args...); err != nil {
+		return nil, err
+	}
+
+	// Amount, currency or date may have changed
+	if err := updateBaseAmounts(q, "id = ?", before.ID); err != nil {
+		return nil, err
+	}
+	if err := refreshFingerprint(q, before.ID); err != nil {
+		return nil, err
+	}
+
+	after, err := getTransaction(q, before.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordHistory(q, action, changed, before, after, actor); err != nil {
+		return nil, err
+	}
+	return after, nil
 }
 
-func DeleteTransaction(id string) error {
-	_, err := db.DB.Exec("DELETE FROM transactions WHERE id = ?", id)
+// patchColumns lists the columns a patch sets and their values. A new amount
+// also replaces amount_original, so the two never disagree.
+func patchColumns(patch models.TransactionPatch) ([]string, []interface{}) {
+	var columns []string
+	var values []interface{}
+	set := func(column string, value interface{}) {
+		columns = append(columns, column)
+		values = append(values, value)
+	}
+
+	if patch.Category != nil {
+		set("category", *patch.Category)
+	}
+	if patch.Source != nil {
+		set("source", *patch.Source)
+	}
+	if patch.Description != nil {
+		set("description", *patch.Description)
+	}
+	if patch.Amount != nil {
+		amount := models.Money(*patch.Amount)
+		set("amount", amount)
+		set("amount_original", amount.String())
+	}
+	if patch.Direction != nil {
+		set("direction", *patch.Direction)
+	}
+	if patch.Currency != nil {
+		set("currency", *patch.Currency)
+	}
+	if patch.IsPaid != nil {
+		isPaid := 0
+		if *patch.IsPaid {
+			isPaid = 1
+		}
+		set("is_paid", isPaid)
+	}
+	if patch.Bank != nil {
+		set("bank", *patch.Bank)
+	}
+	if patch.TransactionDate != nil {
+		if *patch.TransactionDate == "" {
+			set("transaction_date", nil)
+		} else {
+			set("transaction_date", *patch.TransactionDate)
+		}
+	}
+	return columns, values
+}
+
+// edited_fields holds the sorted, comma-separated columns edited by hand
+func parseEditedFields(value string) map[string]bool {
+	fields := make(map[string]bool)
+	for _, f := range strings.Split(value, ",") {
+		if f != "" {
+			fields[f] = true
+		}
+	}
+	return fields
+}
+
+func formatEditedFields(fields map[string]bool) string {
+	var names []string
+	for f := range fields {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// refreshFingerprint recomputes the fingerprint after an edit
+func refreshFingerprint(q queryExecer, id string) error {
+	t, err := getTransaction(q, id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec("UPDATE transactions SET fingerprint = ? WHERE id = ?", t.ComputeFingerprint(), id)
 	return err
 }
 
+// DeleteTransaction moves a transaction to the trash; it returns false when
+// there is no such transaction outside the trash
+func DeleteTransaction(id, actor string) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	t, err := getTransaction(tx, id)
+	if err == sql.ErrNoRows || (err == nil && t.DeletedAt != nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := trashTransaction(tx, t, actor); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// BulkDeleteTransactions moves every transaction matching the filter to the
+// trash and returns how many were deleted
+func BulkDeleteTransactions(filter models.TransactionFilter, actor string) (int, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	transactions, err := matchingTransactions(tx, filter)
+	if err != nil {
+		return 0, err
+	}
+	for i := range transactions {
+		if err := trashTransaction(tx, &transactions[i], actor); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if len(transactions) > 0 {
+		TriggerRecurringDetection()
+	}
+	return len(transactions), nil
+}
+
 func buildWhereClause(filter models.TransactionFilter) (string, []interface{}) {
 	var conditions []string
 	var args []interface{}
@@ -150,6 +496,24 @@ func buildWhereClause(filter models.TransactionFilter) (string, []interface{}) {
 		conditions = append(conditions, "("+strings.Join(nameConditions, " OR ")+")")
 	}
 
+	if len(filter.Categories) > 0 {
+		placeholders := make([]string, len(filter.Categories))
+		for i, cat := range filter.Categories {
+			placeholders[i] = "?"
+			args = append(args, cat)
+		}
+		conditions = append(conditions, fmt.Sprintf("t.category IN (%s)", strings.Join(placeholders, ",")))
+	}
+
+	if len(filter.Sources) > 0 {
+		placeholders := make([]string, len(filter.Sources))
+		for i, src := range filter.Sources {
+			placeholders[i] = "?"
+			args = append(args, src)
+		}
+		conditions = append(conditions, fmt.Sprintf("t.source IN (%s)", strings.Join(placeholders, ",")))
+	}
+
 	if len(filter.ExcludeCategories) > 0 {
 		placeholders := make([]string, len(filter.ExcludeCategories))
 		for i, cat := range filter.ExcludeCategories {
@@ -168,6 +532,24 @@ func buildWhereClause(filter models.TransactionFilter) (string, []interface{}) {
 		conditions = append(conditions, fmt.Sprintf("t.source NOT IN (%s)", strings.Join(placeholders, ",")))
 	}
 
+	if len(filter.Tags) > 0 {
+		placeholders := make([]string, len(filter.Tags))
+		for i, tag := range filter.Tags {
+			placeholders[i] = "?"
+			args = append(args, tag)
+		}
+		conditions = append(conditions, fmt.Sprintf("t.id IN (%s)", taggedTransactions(placeholders)))
+	}
+
+	if len(filter.ExcludeTags) > 0 {
+		placeholders := make([]string, len(filter.ExcludeTags))
+		for i, tag := range filter.ExcludeTags {
+			placeholders[i] = "?"
+			args = append(args, tag)
+		}
+		conditions = append(conditions, fmt.Sprintf("t.id NOT IN (%s)", taggedTransactions(placeholders)))
+	}
+
 	if filter.IsPaid != nil {
 		isPaid := 0
 		if *filter.IsPaid {
@@ -187,9 +569,27 @@ func buildWhereClause(filter models.TransactionFilter) (string, []interface{}) {
 		args = append(args, *filter.DateTo)
 	}
 
+	if filter.Direction != nil {
+		conditions = append(conditions, "t.direction = ?")
+		args = append(args, *filter.Direction)
+	}
+
+	if !filter.IncludeDuplicates {
+		conditions = append(conditions, "t.duplicate_of IS NULL")
+	}
+
+	// Deleted transactions stay in the trash until purged
+	conditions = append(conditions, "t.deleted_at IS NULL")
+
 	if len(conditions) == 0 {
 		return "", args
 	}
 
 	return " WHERE " + strings.Join(conditions, " AND "), args
 }
+
+// taggedTransactions selects the IDs of transactions with any of the tags
+// bound to the placeholders
+func taggedTransactions(placeholders []string) string {
+	return fmt.Sprintf(`SELECT tt.transaction_id FROM transaction_tags tt JOIN tags tg ON tg.id = tt.tag_id WHERE tg.name IN (%s)`, strings.Join(placeholders, ","))
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"kiro-finance-backend/internal/config"
+	"kiro-finance-backend/internal/db"
+	"kiro-finance-backend/internal/models"
+)
+
+// ErrUploadNotStored is returned for files imported before uploads were kept
+var ErrUploadNotStored = errors.New("original upload is not stored")
+
+// uploadPath is where the bytes with this hash live: uploads are stored once
+// per content, in subdirectories named after the first two hash characters
+func uploadPath(contentHash string) string {
+	return filepath.Join(config.Cfg.UploadsDir, contentHash[:2], contentHash)
+}
+
+// StoreUploadContent keeps the uploaded bytes unless the same content is
+// already stored
+func StoreUploadContent(upload Upload) error {
+	path := uploadPath(upload.ContentHash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Written under a temporary name so a partial write is never read back
+	tmp, err := os.CreateTemp(filepath.Dir(path), upload.ContentHash+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(upload.Data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadUploadContent returns the original bytes of a file
+func ReadUploadContent(file *models.File) ([]byte, error) {
+	if len(file.ContentHash) < 2 {
+		return nil, ErrUploadNotStored
+	}
+	data, err := os.ReadFile(uploadPath(file.ContentHash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUploadNotStored
+	}
+	return data, err
+}
+
+// releaseUploadContent removes stored bytes no file refers to anymore
+func releaseUploadContent(contentHash string) error {
+	if len(contentHash) < 2 {
+		return nil
+	}
+	var refs int
+	if err := db.DB.QueryRow("SELECT COUNT(*) FROM files WHERE content_hash = ?", contentHash).Scan(&refs); err != nil {
+		return err
+	}
+	if refs > 0 {
+		return nil
+	}
+	err := os.Remove(uploadPath(contentHash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// layoutProfileID is the import profile a file was parsed with, nil for the
+// built-in layouts
+func layoutProfileID(layout models.ImportLayout) *string {
+	if layout.ProfileID == "" {
+		return nil
+	}
+	id := layout.ProfileID
+	return &id
+}